@@ -0,0 +1,288 @@
+package helmtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/podlogs"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/signals"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Options the options for running the tests of a helm release or rendered chart
+type Options struct {
+	options.BaseOptions
+
+	Namespace    string
+	Release      string
+	Dir          string
+	VerifyResult bool
+	Duration     time.Duration
+	PollPeriod   time.Duration
+	Out          io.Writer
+	KubeClient   kubernetes.Interface
+	LogSource    podlogs.LogSource
+}
+
+// TestResult the result of a chart test
+type TestResult struct {
+	Name     string
+	Kind     string
+	Error    error
+	Duration time.Duration
+}
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Runs the tests of a helm chart without the helm binary
+
+		The tests are the Jobs and Pods annotated with 'helm.sh/hook: test' in the latest version of the release stored in the namespace or in the YAML files of a rendered chart directory such as from 'helm template --output-dir'.
+
+		The tests are run one at a time ordered by their 'helm.sh/hook-weight' annotation and then name. Each test is tailed and verified in the same way as 'jx verify job' so --verify-result can be used to check the result the test logs. Test Pods are run as Jobs which do not retry so that they are verified in the same way.
+
+		The 'helm.sh/hook-delete-policy' annotation of each test is used to decide when to delete it. If there is no policy the previous test is deleted before the test is created again.
+`)
+
+	cmdExample = templates.Examples(`
+		# run the tests of the release
+		jx verify helm-test --release my-app
+
+		# run the tests of a rendered chart verifying the results the tests log
+		helm template my-app charts/my-app --output-dir build
+		jx verify helm-test --dir build --verify-result
+`)
+)
+
+// NewCmdVerifyHelmTest creates the new command
+func NewCmdVerifyHelmTest() (*cobra.Command, *Options) {
+	o := &Options{}
+	command := &cobra.Command{
+		Use:     "helm-test",
+		Short:   "Runs the tests of a helm chart without the helm binary",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			signals.RunUntilSignalled(o.RunWithContext)
+		},
+	}
+	command.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace of the release and where the tests run. If not specified the current namespace is used")
+	command.Flags().StringVarP(&o.Release, "release", "r", "", "the name of the helm release to test")
+	command.Flags().StringVarP(&o.Dir, "dir", "", "", "the directory of a rendered chart to find the tests in instead of the release")
+	command.Flags().BoolVarP(&o.VerifyResult, "verify-result", "", false, "if the test pod succeeds lets look for the result in the container termination message or the last line of the log starting with "+job.PodResultPrefix+" to determine the test result")
+	command.Flags().DurationVarP(&o.Duration, "duration", "d", time.Minute*10, "how long to wait for each test to complete")
+	command.Flags().DurationVarP(&o.PollPeriod, "poll", "", time.Second*2, "the period between checks that a previous test has been deleted")
+
+	o.BaseOptions.AddBaseFlags(command)
+	return command, o
+}
+
+// Validate checks exactly one of the release or chart directory is given and lazily creates the kubernetes and log clients
+func (o *Options) Validate() error {
+	if o.Release == "" && o.Dir == "" {
+		return options.MissingOption("release")
+	}
+	if o.Release != "" && o.Dir != "" {
+		return fmt.Errorf("only one of --release and --dir can be specified")
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+
+	var err error
+	o.KubeClient, err = kube.LazyCreateKubeClientWithMandatory(o.KubeClient, true)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	if o.LogSource == nil {
+		o.LogSource = podlogs.NewKubeLogSource(o.KubeClient)
+	}
+	if o.Namespace == "" {
+		o.Namespace, err = kubeclient.CurrentNamespace()
+		if err != nil {
+			return fmt.Errorf("failed to detect current namespace. Try supply --namespace: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run runs the command
+func (o *Options) Run() error {
+	return o.RunWithContext(context.Background())
+}
+
+// RunWithContext runs the tests one at a time until they complete or the context is cancelled
+func (o *Options) RunWithContext(ctx context.Context) error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	ns := o.Namespace
+
+	var tests []*testHook
+	source := ""
+	if o.Dir != "" {
+		source = "directory " + o.Dir
+		tests, err = loadDirTests(o.Dir)
+	} else {
+		source = "release " + o.Release
+		tests, err = o.loadReleaseTests(ctx, ns, o.Release)
+	}
+	if err != nil {
+		return err
+	}
+	if len(tests) == 0 {
+		return fmt.Errorf("no tests annotated with %s: %s found in %s", HookAnnotation, HookTest, source)
+	}
+
+	var results []TestResult
+	for _, h := range tests {
+		r := o.runTest(ctx, ns, h)
+		if ctx.Err() != nil {
+			return fmt.Errorf("helm tests cancelled: %w", ctx.Err())
+		}
+		results = append(results, r)
+	}
+	return o.reportTestResults(results)
+}
+
+// runTest creates the Job of the test then verifies it and deletes it using the delete policies of the test
+func (o *Options) runTest(ctx context.Context, ns string, h *testHook) TestResult {
+	r := TestResult{
+		Name: h.Name,
+		Kind: h.Kind,
+	}
+	start := time.Now()
+	r.Error = o.createTest(ctx, ns, h)
+	if r.Error == nil {
+		logger.Logger().Infof("running test %s %s in namespace %s", h.Kind, info(h.Name), info(ns))
+
+		_, jo := job.NewCmdVerifyJob()
+		jo.BatchMode = true
+		jo.Namespace = ns
+		jo.Name = h.Name
+		jo.VerifyResult = o.VerifyResult
+		jo.Duration = o.Duration
+		jo.Out = o.Out
+		jo.KubeClient = o.KubeClient
+		jo.LogSource = o.LogSource
+		r.Error = jo.RunWithContext(ctx)
+	}
+	r.Duration = time.Since(start)
+	if ctx.Err() != nil {
+		return r
+	}
+
+	if (r.Error == nil && h.hasDeletePolicy(HookSucceeded)) || (r.Error != nil && h.hasDeletePolicy(HookFailed)) {
+		err := o.deleteTest(ctx, ns, h.Name)
+		if err != nil {
+			logger.Logger().Warnf("%s", err.Error())
+		} else {
+			logger.Logger().Infof("deleted test %s in namespace %s", info(h.Name), info(ns))
+		}
+	}
+	return r
+}
+
+// createTest creates the Job of the test deleting any previous Job of the test first if the delete policy allows
+func (o *Options) createTest(ctx context.Context, ns string, h *testHook) error {
+	jobs := o.KubeClient.BatchV1().Jobs(ns)
+	if h.hasDeletePolicy(HookBeforeHookCreation) {
+		err := o.deleteTest(ctx, ns, h.Name)
+		if err != nil {
+			return err
+		}
+		err = o.waitForTestToBeDeleted(ctx, ns, h.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	j := h.Job.DeepCopy()
+	j.Namespace = ns
+	_, err := jobs.Create(ctx, j, metav1.CreateOptions{})
+	if err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("test %s already exists in namespace %s and its %s annotation does not include %s: %w", h.Name, ns, HookDeletePolicyAnnotation, HookBeforeHookCreation, err)
+		}
+		return fmt.Errorf("failed to create the Job of test %s in namespace %s: %w", h.Name, ns, err)
+	}
+	return nil
+}
+
+// deleteTest deletes the Job of the test along with its pods ignoring it if it does not exist
+func (o *Options) deleteTest(ctx context.Context, ns, name string) error {
+	propagationPolicy := metav1.DeletePropagationForeground
+	err := o.KubeClient.BatchV1().Jobs(ns).Delete(ctx, name, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete the Job of test %s in namespace %s: %w", name, ns, err)
+	}
+	return nil
+}
+
+// waitForTestToBeDeleted waits for the foreground deletion of the Job of a previous test so that the pods of the
+// previous test are not verified instead of the new ones
+func (o *Options) waitForTestToBeDeleted(ctx context.Context, ns, name string) error {
+	timeEnd := time.Now().Add(o.Duration)
+	for {
+		_, err := o.KubeClient.BatchV1().Jobs(ns).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get the Job of test %s in namespace %s: %w", name, ns, err)
+		}
+		if time.Now().After(timeEnd) {
+			return fmt.Errorf("timed out after waiting for duration %s for the previous Job of test %s to be deleted", o.Duration.String(), name)
+		}
+		timer := time.NewTimer(o.PollPeriod)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reportTestResults displays the result of each test returning an error if any test failed
+func (o *Options) reportTestResults(results []TestResult) error {
+	var failed []string
+	t := table.CreateTable(o.Out)
+	t.AddRow("TEST", "KIND", "RESULT", "DURATION")
+	for i := range results {
+		r := &results[i]
+		result := info("Succeeded")
+		if r.Error != nil {
+			result = termcolor.ColorError("Failed")
+			failed = append(failed, r.Name)
+			logger.Logger().Infof("test %s failed: %s", info(r.Name), r.Error.Error())
+		}
+		t.AddRow(r.Name, r.Kind, result, r.Duration.Round(time.Second).String())
+	}
+	t.Render()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d tests failed: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+	return nil
+}
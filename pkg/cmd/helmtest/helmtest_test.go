@@ -0,0 +1,272 @@
+package helmtest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/helmtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	connectionTest = `apiVersion: v1
+kind: Pod
+metadata:
+  name: my-app-test-connection
+  annotations:
+    helm.sh/hook: test
+spec:
+  containers:
+  - name: wget
+    image: busybox
+    command: ['wget', 'my-app:80']
+  restartPolicy: Never
+`
+	failingTest = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: my-app-test-fails
+  annotations:
+    helm.sh/hook: test
+    helm.sh/hook-weight: "-1"
+    helm.sh/hook-delete-policy: hook-succeeded
+spec:
+  template:
+    spec:
+      containers:
+      - name: test
+        image: busybox
+      restartPolicy: Never
+`
+	configMapTest = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-app-test-config
+  annotations:
+    helm.sh/hook: test
+`
+	preInstallHook = `apiVersion: batch/v1
+kind: Job
+metadata:
+  name: my-app-migrate
+  annotations:
+    helm.sh/hook: pre-install
+`
+)
+
+func TestVerifyHelmTestRelease(t *testing.T) {
+	ns := "jx"
+
+	// the previous run of the connection test should be replaced
+	previous := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app-test-connection",
+			Namespace: ns,
+		},
+	}
+	kubeClient := fake.NewSimpleClientset(
+		newReleaseSecret(t, ns, "my-app", 1, "", connectionTest),
+		newReleaseSecret(t, ns, "my-app", 2, "", connectionTest, failingTest, configMapTest, preInstallHook),
+		previous,
+	)
+	created := completeJobs(kubeClient, ns, nil)
+
+	_, o := helmtest.NewCmdVerifyHelmTest()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Release = "my-app"
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as one test failed")
+	assert.Equal(t, "1 of 2 tests failed: my-app-test-fails", err.Error())
+
+	text := out.String()
+	t.Logf("output:\n%s\n", text)
+	assert.Regexp(t, `my-app-test-fails\s+Job\s+\S*Failed`, text, "should report the failed test")
+	assert.Regexp(t, `my-app-test-connection\s+Pod\s+\S*Succeeded`, text, "should report the successful test")
+
+	assert.Equal(t, []string{"my-app-test-fails", "my-app-test-connection"}, created.names(), "should run the tests in weight order")
+
+	j, err := kubeClient.BatchV1().Jobs(ns).Get(context.TODO(), "my-app-test-connection", metav1.GetOptions{})
+	require.NoError(t, err, "should keep the connection test as it has no delete policy")
+	require.NotNil(t, j.Spec.BackoffLimit, "should not retry the test pod")
+	assert.Equal(t, int32(0), *j.Spec.BackoffLimit)
+	assert.Equal(t, "wget", j.Spec.Template.Spec.Containers[0].Name, "should have recreated the test")
+
+	_, err = kubeClient.BatchV1().Jobs(ns).Get(context.TODO(), "my-app-test-fails", metav1.GetOptions{})
+	assert.NoError(t, err, "should keep the failed test as it is only deleted if it succeeds")
+}
+
+func TestVerifyHelmTestDir(t *testing.T) {
+	ns := "jx"
+	dir := t.TempDir()
+	testsDir := filepath.Join(dir, "my-app", "templates", "tests")
+	require.NoError(t, os.MkdirAll(testsDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(testsDir, "test-connection.yaml"), []byte("---\n"+connectionTest), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "my-app", "templates", "job.yaml"), []byte(preInstallHook), 0o600))
+
+	kubeClient := fake.NewSimpleClientset()
+	completeJobs(kubeClient, ns, map[string]string{
+		"my-app-test-connection": "FAILED: could not connect",
+	})
+
+	_, o := helmtest.NewCmdVerifyHelmTest()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Dir = dir
+	o.VerifyResult = true
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed using the result of the test")
+	assert.Equal(t, "1 of 1 tests failed: my-app-test-connection", err.Error())
+	assert.Regexp(t, `my-app-test-connection\s+Pod\s+\S*Failed`, out.String())
+}
+
+func TestVerifyHelmTestNoTests(t *testing.T) {
+	ns := "jx"
+	_, o := helmtest.NewCmdVerifyHelmTest()
+	o.KubeClient = fake.NewSimpleClientset(newReleaseSecret(t, ns, "my-app", 1, "", preInstallHook))
+	o.Namespace = ns
+	o.Release = "my-app"
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.Error(t, err, "should fail if there are no tests")
+	assert.Contains(t, err.Error(), "no tests annotated with helm.sh/hook: test found in release my-app")
+}
+
+// createdJobs the names of the Jobs created in order
+type createdJobs struct {
+	lock  sync.Mutex
+	items []string
+}
+
+func (c *createdJobs) names() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return append([]string{}, c.items...)
+}
+
+// completeJobs completes the Jobs as they are created failing any with a name containing 'fails' and creating a
+// pod with the given termination message for any Job in the results
+func completeJobs(kubeClient *fake.Clientset, ns string, results map[string]string) *createdJobs {
+	created := &createdJobs{}
+	kubeClient.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		j := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		created.lock.Lock()
+		created.items = append(created.items, j.Name)
+		created.lock.Unlock()
+
+		conditionType := batchv1.JobComplete
+		if strings.Contains(j.Name, "fails") {
+			conditionType = batchv1.JobFailed
+		}
+		j.Status.Conditions = []batchv1.JobCondition{
+			{
+				Type:   conditionType,
+				Status: corev1.ConditionTrue,
+			},
+		}
+		if message, ok := results[j.Name]; ok {
+			err := kubeClient.Tracker().Add(newTestPod(ns, j.Name, message))
+			if err != nil {
+				return true, nil, err
+			}
+		}
+		return false, nil, nil
+	})
+	return created
+}
+
+func newTestPod(ns, jobName, message string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName + "-abc",
+			Namespace: ns,
+			Labels: map[string]string{
+				"job-name": jobName,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "test",
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodSucceeded,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "test",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							Message: message,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// newReleaseSecret creates a secret storing a release in the same way as helm with the manifests as hooks
+func newReleaseSecret(t *testing.T, ns, name string, version int, manifest string, hooks ...string) *corev1.Secret {
+	release := map[string]interface{}{
+		"name":     name,
+		"version":  version,
+		"manifest": manifest,
+	}
+	var hookList []interface{}
+	for _, h := range hooks {
+		hookList = append(hookList, map[string]interface{}{
+			"manifest": h,
+		})
+	}
+	release["hooks"] = hookList
+	data, err := json.Marshal(release)
+	require.NoError(t, err, "failed to marshal release")
+
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err = w.Write(data)
+	require.NoError(t, err, "failed to gzip release")
+	require.NoError(t, w.Close(), "failed to gzip release")
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sh.helm.release.v1." + name + ".v" + strconv.Itoa(version),
+			Namespace: ns,
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    name,
+				"version": strconv.Itoa(version),
+				"status":  "deployed",
+			},
+		},
+		Type: "helm.sh/release.v1",
+		Data: map[string][]byte{
+			"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes())),
+		},
+	}
+}
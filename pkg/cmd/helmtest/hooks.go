@@ -0,0 +1,325 @@
+package helmtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+const (
+	// HookAnnotation the annotation helm uses to mark a resource as a hook
+	HookAnnotation = "helm.sh/hook"
+
+	// HookWeightAnnotation the annotation helm uses to order hooks
+	HookWeightAnnotation = "helm.sh/hook-weight"
+
+	// HookDeletePolicyAnnotation the annotation helm uses to decide when to delete a hook
+	HookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+
+	// HookTest the hook event of chart tests
+	HookTest = "test"
+
+	// hookTestSuccess the hook event of chart tests in helm 2 which helm 3 still supports
+	hookTestSuccess = "test-success"
+
+	// HookBeforeHookCreation deletes the previous test before creating it again
+	HookBeforeHookCreation = "before-hook-creation"
+
+	// HookSucceeded deletes the test if it succeeds
+	HookSucceeded = "hook-succeeded"
+
+	// HookFailed deletes the test if it fails
+	HookFailed = "hook-failed"
+
+	// releaseSecretType the type of the secrets helm stores releases in
+	releaseSecretType = "helm.sh/release.v1"
+)
+
+// testHook a chart test which is run as a Job
+type testHook struct {
+	Name           string
+	Kind           string
+	Weight         int
+	DeletePolicies []string
+	Job            *batchv1.Job
+}
+
+// hasDeletePolicy returns true if the test has the given delete policy. Like helm if there is no delete policy
+// the test is deleted before it is created again
+func (h *testHook) hasDeletePolicy(policy string) bool {
+	if len(h.DeletePolicies) == 0 {
+		return policy == HookBeforeHookCreation
+	}
+	for _, p := range h.DeletePolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// helmRelease the parts of a release stored by helm that we need
+type helmRelease struct {
+	Name     string `json:"name"`
+	Version  int    `json:"version"`
+	Manifest string `json:"manifest"`
+	Hooks    []struct {
+		Name     string   `json:"name"`
+		Kind     string   `json:"kind"`
+		Manifest string   `json:"manifest"`
+		Events   []string `json:"events"`
+	} `json:"hooks"`
+}
+
+// loadReleaseTests loads the tests of the latest version of the release from the secrets helm stores releases in
+func (o *Options) loadReleaseTests(ctx context.Context, ns, releaseName string) ([]*testHook, error) {
+	selector := "owner=helm,name=" + releaseName
+	secretList, err := o.KubeClient.CoreV1().Secrets(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets in namespace %s with selector %s: %w", ns, selector, err)
+	}
+	var latest *corev1.Secret
+	latestVersion := -1
+	for i := range secretList.Items {
+		secret := &secretList.Items[i]
+		if secret.Type != releaseSecretType {
+			continue
+		}
+		version, err := strconv.Atoi(secret.Labels["version"])
+		if err != nil {
+			continue
+		}
+		if version > latestVersion {
+			latest = secret
+			latestVersion = version
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("could not find helm release %s in namespace %s", releaseName, ns)
+	}
+
+	release, err := decodeRelease(latest.Data["release"])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode helm release %s from secret %s: %w", releaseName, latest.Name, err)
+	}
+	logger.Logger().Infof("found version %d of helm release %s in namespace %s", release.Version, info(releaseName), info(ns))
+
+	manifests := []string{release.Manifest}
+	for i := range release.Hooks {
+		manifests = append(manifests, release.Hooks[i].Manifest)
+	}
+	var answer []*testHook
+	for i, manifest := range manifests {
+		source := "manifest of release " + releaseName
+		if i > 0 {
+			source = "hooks of release " + releaseName
+			if release.Hooks[i-1].Name != "" {
+				source = "hook " + release.Hooks[i-1].Name + " of release " + releaseName
+			}
+		}
+		tests, err := parseTestHooks(manifest, source)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, tests...)
+	}
+	return sortTestHooks(answer), nil
+}
+
+// decodeRelease decodes a release stored by helm as base64 encoded and optionally gzipped JSON
+func decodeRelease(data []byte) (*helmRelease, error) {
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64: %w", err)
+	}
+	if bytes.HasPrefix(decoded, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(decoded))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip: %w", err)
+		}
+		defer r.Close()
+		decoded, err = io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip: %w", err)
+		}
+	}
+	release := &helmRelease{}
+	err = json.Unmarshal(decoded, release)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	}
+	return release, nil
+}
+
+// loadDirTests loads the tests from the YAML files in a rendered chart directory such as from 'helm template --output-dir'
+func loadDirTests(dir string) ([]*testHook, error) {
+	var answer []*testHook
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() || (!strings.HasSuffix(path, ".yaml") && !strings.HasSuffix(path, ".yml")) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", path, err)
+		}
+		tests, err := parseTestHooks(string(data), "file "+path)
+		if err != nil {
+			return err
+		}
+		answer = append(answer, tests...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the tests in directory %s: %w", dir, err)
+	}
+	return sortTestHooks(answer), nil
+}
+
+// parseTestHooks returns the test hooks in the YAML documents
+func parseTestHooks(manifest, source string) ([]*testHook, error) {
+	var answer []*testHook
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(manifest), 4096)
+	for {
+		u := &unstructured.Unstructured{}
+		err := decoder.Decode(&u.Object)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+		if len(u.Object) == 0 || !isTestHook(u.GetAnnotations()[HookAnnotation]) {
+			continue
+		}
+
+		h, err := toTestHook(u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load test %s %s from %s: %w", u.GetKind(), u.GetName(), source, err)
+		}
+		if h == nil {
+			logger.Logger().Warnf("ignoring test %s %s in %s as only Pod and Job tests are supported", u.GetKind(), u.GetName(), source)
+			continue
+		}
+		answer = append(answer, h)
+	}
+	return answer, nil
+}
+
+func isTestHook(hooks string) bool {
+	for _, hook := range strings.Split(hooks, ",") {
+		hook = strings.TrimSpace(hook)
+		if hook == HookTest || hook == hookTestSuccess {
+			return true
+		}
+	}
+	return false
+}
+
+// toTestHook converts the Pod or Job resource into a test returning nil if it is some other kind
+func toTestHook(u *unstructured.Unstructured) (*testHook, error) {
+	annotations := u.GetAnnotations()
+	h := &testHook{
+		Name: u.GetName(),
+		Kind: u.GetKind(),
+	}
+	if h.Name == "" {
+		return nil, fmt.Errorf("missing name")
+	}
+	weight := strings.TrimSpace(annotations[HookWeightAnnotation])
+	if weight != "" {
+		var err error
+		h.Weight, err = strconv.Atoi(weight)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s annotation %s: %w", HookWeightAnnotation, weight, err)
+		}
+	}
+	for _, p := range strings.Split(annotations[HookDeletePolicyAnnotation], ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			h.DeletePolicies = append(h.DeletePolicies, p)
+		}
+	}
+
+	switch h.Kind {
+	case "Job":
+		h.Job = &batchv1.Job{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, h.Job)
+		if err != nil {
+			return nil, err
+		}
+	case "Pod":
+		pod := &corev1.Pod{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, pod)
+		if err != nil {
+			return nil, err
+		}
+		h.Job = podToJob(pod)
+	default:
+		return nil, nil
+	}
+	return h, nil
+}
+
+// podToJob wraps the test pod in a Job which does not retry so that it can be verified like any other Job
+func podToJob(pod *corev1.Pod) *batchv1.Job {
+	backoffLimit := int32(0)
+	spec := *pod.Spec.DeepCopy()
+	if spec.RestartPolicy != corev1.RestartPolicyOnFailure {
+		spec.RestartPolicy = corev1.RestartPolicyNever
+	}
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        pod.Name,
+			Labels:      pod.Labels,
+			Annotations: pod.Annotations,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      pod.Labels,
+					Annotations: pod.Annotations,
+				},
+				Spec: spec,
+			},
+		},
+	}
+}
+
+// sortTestHooks sorts the tests by weight and then name in the same order as helm
+func sortTestHooks(tests []*testHook) []*testHook {
+	sort.SliceStable(tests, func(i, j int) bool {
+		if tests[i].Weight != tests[j].Weight {
+			return tests[i].Weight < tests[j].Weight
+		}
+		return tests[i].Name < tests[j].Name
+	})
+	return tests
+}
@@ -0,0 +1,203 @@
+package job
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// LogManifestFileName the name of the manifest file written for each job when archiving logs
+const LogManifestFileName = "manifest.yaml"
+
+// LogManifest describes the archived logs of the pods of a job
+type LogManifest struct {
+	Job  string        `json:"job"`
+	Pods []PodLogEntry `json:"pods,omitempty"`
+}
+
+// PodLogEntry describes an archived pod attempt
+type PodLogEntry struct {
+	Name       string              `json:"name"`
+	Phase      corev1.PodPhase     `json:"phase"`
+	StartTime  *metav1.Time        `json:"startTime,omitempty"`
+	Containers []ContainerLogEntry `json:"containers,omitempty"`
+}
+
+// ContainerLogEntry describes the archived log of a container
+type ContainerLogEntry struct {
+	Name            string `json:"name"`
+	Init            bool   `json:"init,omitempty"`
+	ExitCode        *int32 `json:"exitCode,omitempty"`
+	Reason          string `json:"reason,omitempty"`
+	RestartCount    int32  `json:"restartCount,omitempty"`
+	LogFile         string `json:"logFile,omitempty"`
+	PreviousLogFile string `json:"previousLogFile,omitempty"`
+}
+
+// archiveJobLogs saves the logs of every container of the pods of the job along with a manifest
+func (o *Options) archiveJobLogs(ctx context.Context, ns, jobName string, podItems []corev1.Pod) error {
+	dir := filepath.Join(o.logDir, jobName)
+	err := os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	manifest := &LogManifest{
+		Job: jobName,
+	}
+	for i := range podItems {
+		pod := &podItems[i]
+		entry := PodLogEntry{
+			Name:      pod.Name,
+			Phase:     pod.Status.Phase,
+			StartTime: pod.Status.StartTime,
+		}
+		for j := range pod.Spec.InitContainers {
+			c := o.archiveContainerLog(ctx, ns, dir, pod, pod.Spec.InitContainers[j].Name, true)
+			entry.Containers = append(entry.Containers, c)
+		}
+		for j := range pod.Spec.Containers {
+			c := o.archiveContainerLog(ctx, ns, dir, pod, pod.Spec.Containers[j].Name, false)
+			entry.Containers = append(entry.Containers, c)
+		}
+		manifest.Pods = append(manifest.Pods, entry)
+	}
+
+	fileName := filepath.Join(dir, LogManifestFileName)
+	err = yamls.SaveFile(manifest, fileName)
+	if err != nil {
+		return fmt.Errorf("failed to save log manifest %s: %w", fileName, err)
+	}
+	logger.Logger().Infof("saved the logs of %d pods of job %s to %s", len(podItems), info(jobName), info(dir))
+	return nil
+}
+
+func (o *Options) archiveContainerLog(ctx context.Context, ns, dir string, pod *corev1.Pod, containerName string, initContainer bool) ContainerLogEntry {
+	entry := ContainerLogEntry{
+		Name: containerName,
+		Init: initContainer,
+	}
+	status := findContainerStatus(pod, containerName, initContainer)
+	if status != nil {
+		entry.RestartCount = status.RestartCount
+		terminated := status.State.Terminated
+		if terminated == nil {
+			terminated = status.LastTerminationState.Terminated
+		}
+		if terminated != nil {
+			exitCode := terminated.ExitCode
+			entry.ExitCode = &exitCode
+			entry.Reason = terminated.Reason
+		}
+	}
+
+	entry.LogFile = o.saveContainerLog(ctx, ns, dir, pod.Name, containerName, false)
+	if entry.RestartCount > 0 {
+		entry.PreviousLogFile = o.saveContainerLog(ctx, ns, dir, pod.Name, containerName, true)
+	}
+	return entry
+}
+
+// saveContainerLog saves the log of the container returning the file name relative to the job directory
+// or an empty string if the log could not be saved
+func (o *Options) saveContainerLog(ctx context.Context, ns, dir, podName, containerName string, previous bool) string {
+	data, err := o.readLog(ctx, ns, podName, &corev1.PodLogOptions{
+		Container: containerName,
+		Previous:  previous,
+	})
+	if err != nil {
+		logger.Logger().Warnf("failed to read logs in namespace %s pod %s container %s: %s", ns, podName, containerName, err.Error())
+		return ""
+	}
+
+	name := containerName + ".log"
+	if previous {
+		name = containerName + ".previous.log"
+	}
+	relPath := filepath.Join(podName, name)
+	path := filepath.Join(dir, relPath)
+	err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err == nil {
+		err = os.WriteFile(path, data, files.DefaultFileWritePermissions)
+	}
+	if err != nil {
+		logger.Logger().Warnf("failed to save log file %s: %s", path, err.Error())
+		return ""
+	}
+	return relPath
+}
+
+// createTarball creates a gzipped tarball of the contents of the directory which is empty if the directory does not exist
+func createTarball(dir, fileName string) error {
+	err := os.MkdirAll(filepath.Dir(fileName), files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", fileName, err)
+	}
+	f, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("failed to create file %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	err = filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				// no logs were saved such as if the job had no pods so lets create an empty archive
+				return nil
+			}
+			return err
+		}
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if relPath == "." {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relPath)
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			return nil
+		}
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		_, err = io.Copy(tw, src)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add %s to tarball %s: %w", dir, fileName, err)
+	}
+	err = tw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close tarball %s: %w", fileName, err)
+	}
+	err = gw.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close tarball %s: %w", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// PodArtifactBeginPrefix the prefix of the line which starts an artifact followed by the file name of the artifact
+	PodArtifactBeginPrefix = "POD ARTIFACT BEGIN "
+
+	// PodArtifactEnd the line which ends an artifact
+	PodArtifactEnd = "POD ARTIFACT END"
+)
+
+// artifactWriter passes lines through to the underlying writer apart from artifacts which are decoded from the
+// base64 lines between the PodArtifactBeginPrefix and PodArtifactEnd lines and saved in the directory
+type artifactWriter struct {
+	out     io.Writer
+	dir     string
+	podName string
+	buffer  []byte
+	name    string
+	data    strings.Builder
+	started bool
+}
+
+// newArtifactWriter creates a writer which saves the artifacts of the pod of the job if an artifacts directory is configured
+func (o *Options) newArtifactWriter(out io.Writer, jobName, podName string) *artifactWriter {
+	w := &artifactWriter{
+		out:     out,
+		podName: podName,
+	}
+	if o.ArtifactsDir != "" {
+		w.dir = filepath.Join(o.ArtifactsDir, jobName)
+	}
+	return w
+}
+
+// podLogWriter writes the tailed log of a pod. It is kept for the pod across reconnections of the log so that an
+// artifact logged while the log is reset is not lost
+type podLogWriter struct {
+	artifacts *artifactWriter
+	prefixed  *prefixWriter
+}
+
+// newPodLogWriter creates a writer of the log of the pod of the job to the output prefixing each line with the job
+// and pod when verifying jobs concurrently so each job can be followed
+func (o *Options) newPodLogWriter(jobName, podName string) *podLogWriter {
+	l := &podLogWriter{}
+	var out io.Writer = o.Out
+	if o.prefixJobs {
+		l.prefixed = o.newPrefixWriter(jobName + "/" + podName)
+		out = l.prefixed
+	}
+	l.artifacts = o.newArtifactWriter(out, jobName, podName)
+	return l
+}
+
+// Flush writes any remaining partial line once the log has ended
+func (l *podLogWriter) Flush() {
+	if l == nil || l.artifacts == nil {
+		return
+	}
+	err := l.artifacts.Flush()
+	if err == nil && l.prefixed != nil {
+		err = l.prefixed.Flush()
+	}
+	if err != nil {
+		logger.Logger().Warnf("failed to write log of pod %s: %s", l.artifacts.podName, err.Error())
+	}
+}
+
+// saveArtifacts saves the artifacts in the log of a pod which was not tailed
+func (o *Options) saveArtifacts(ctx context.Context, ns, jobName, podName string) {
+	data, err := o.readLog(ctx, ns, podName, &corev1.PodLogOptions{
+		Container: o.ContainerName,
+	})
+	if err != nil {
+		logger.Logger().Warnf("failed to read the log of pod %s to save its artifacts: %s", podName, err.Error())
+		return
+	}
+	o.writeArtifacts(jobName, podName, data)
+}
+
+// writeArtifacts saves the artifacts in the complete log of a pod discarding the rest of the log
+func (o *Options) writeArtifacts(jobName, podName string, data []byte) {
+	w := o.newArtifactWriter(io.Discard, jobName, podName)
+	_, err := w.Write(data)
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		logger.Logger().Warnf("failed to save the artifacts of pod %s: %s", podName, err.Error())
+	}
+}
+
+func (w *artifactWriter) Write(p []byte) (int, error) {
+	if w.dir == "" {
+		return w.out.Write(p)
+	}
+	w.buffer = append(w.buffer, p...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		err := w.writeLine(w.buffer[:i+1])
+		w.buffer = w.buffer[i+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes any remaining partial line and warns about any incomplete artifact
+func (w *artifactWriter) Flush() error {
+	var err error
+	if len(w.buffer) > 0 {
+		w.buffer = append(w.buffer, '\n')
+		line := w.buffer
+		w.buffer = nil
+		err = w.writeLine(line)
+	}
+	if w.started {
+		logger.Logger().Warnf("the log of pod %s ended before the end of artifact %s", w.podName, w.name)
+		w.started = false
+		w.data.Reset()
+	}
+	return err
+}
+
+func (w *artifactWriter) writeLine(line []byte) error {
+	text := strings.TrimSpace(string(line))
+	switch {
+	case w.started && text == PodArtifactEnd:
+		w.started = false
+		w.saveArtifact()
+		w.data.Reset()
+		return nil
+
+	case w.started:
+		w.data.WriteString(text)
+		return nil
+
+	case strings.HasPrefix(text, PodArtifactBeginPrefix):
+		w.name = strings.TrimSpace(strings.TrimPrefix(text, PodArtifactBeginPrefix))
+		w.started = true
+		return nil
+
+	default:
+		_, err := w.out.Write(line)
+		return err
+	}
+}
+
+func (w *artifactWriter) saveArtifact() {
+	err := w.writeArtifact()
+	if err != nil {
+		logger.Logger().Warnf("failed to save artifact %s of pod %s: %s", w.name, w.podName, err.Error())
+	}
+}
+
+func (w *artifactWriter) writeArtifact() error {
+	name := filepath.Clean(filepath.FromSlash(w.name))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("invalid artifact name %s", w.name)
+	}
+	data, err := base64.StdEncoding.DecodeString(w.data.String())
+	if err != nil {
+		return fmt.Errorf("failed to decode base64: %w", err)
+	}
+	path := filepath.Join(w.dir, name)
+	err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	err = os.WriteFile(path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	logger.Logger().Infof("saved artifact %s of pod %s to %s", info(w.name), info(w.podName), info(path))
+	return nil
+}
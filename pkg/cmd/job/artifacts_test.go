@@ -0,0 +1,170 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestVerifyJobSavesArtifacts(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	report := "<testsuites>\n  <testsuite name=\"bdd\" tests=\"1\"/>\n</testsuites>\n"
+	encoded := base64.StdEncoding.EncodeToString([]byte(report))
+	log := "running tests\n" +
+		job.PodArtifactBeginPrefix + "reports/junit.xml\n" +
+		encoded[:20] + "\n" +
+		encoded[20:] + "\n" +
+		job.PodArtifactEnd + "\n" +
+		job.PodArtifactBeginPrefix + "../escape.txt\n" +
+		base64.StdEncoding.EncodeToString([]byte("nope")) + "\n" +
+		job.PodArtifactEnd + "\n" +
+		"tests passed\n"
+
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName), newRunningJobPod(ns, podName, jobName))
+
+	dir := t.TempDir()
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{podName: log},
+		OnFollow: func(podName, _ string) {
+			completeJob(t, kubeClient, ns, jobName, podName)
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.ArtifactsDir = filepath.Join(dir, "artifacts")
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+
+	data, err := os.ReadFile(filepath.Join(o.ArtifactsDir, jobName, "reports", "junit.xml"))
+	require.NoError(t, err, "should have saved the artifact")
+	assert.Equal(t, report, string(data), "artifact content")
+
+	assert.NoFileExists(t, filepath.Join(o.ArtifactsDir, "escape.txt"), "should not save artifacts outside of the job directory")
+	assert.NoFileExists(t, filepath.Join(dir, "escape.txt"), "should not save artifacts outside of the artifacts directory")
+
+	assert.Equal(t, "running tests\ntests passed\n", out.String(), "should hide the artifacts from the output")
+}
+
+func TestVerifyJobSavesArtifactsOfCompletedJob(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	log := func(text string) string {
+		return "running tests\n" +
+			job.PodArtifactBeginPrefix + "result.txt\n" +
+			base64.StdEncoding.EncodeToString([]byte(text)) + "\n" +
+			job.PodArtifactEnd + "\n"
+	}
+
+	// the pods completed before we started so their logs were never tailed
+	first := newJobPod(ns, "bdd-abc", jobName, corev1.PodFailed, 1)
+	second := newJobPod(ns, "bdd-def", jobName, corev1.PodSucceeded, 0)
+	second.CreationTimestamp.Time = first.CreationTimestamp.Add(time.Minute)
+	kubeClient := newWatchedClientset(newFinishedJob(ns, jobName, nil, batchv1.JobComplete), first, second)
+
+	dir := t.TempDir()
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			"bdd-abc": log("attempt 1"),
+			"bdd-def": log("attempt 2"),
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.ArtifactsDir = dir
+	o.Duration = 10 * time.Second
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+
+	data, err := os.ReadFile(filepath.Join(dir, jobName, "result.txt"))
+	require.NoError(t, err, "should have saved the artifact of the completed job")
+	assert.Equal(t, "attempt 2", string(data), "should save the artifacts of the last attempt last")
+}
+
+// reconnectingLogSource returns the log in two parts. The first stream ends part way through the log before the
+// pod has completed so the pod is tailed again from the last line
+type reconnectingLogSource struct {
+	lines    []string
+	split    int
+	opens    int
+	onResume func(podName string)
+}
+
+func (s *reconnectingLogSource) OpenLog(_ context.Context, _, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	s.opens++
+	lines := s.lines[:s.split]
+	if s.opens > 1 {
+		s.onResume(podName)
+		lines = s.lines[s.split-1:]
+	}
+	return io.NopCloser(strings.NewReader(strings.Join(lines, "\n") + "\n")), nil
+}
+
+func TestVerifyJobSavesArtifactsAcrossReconnects(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	encoded := base64.StdEncoding.EncodeToString([]byte("<testsuites/>\n"))
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName), newRunningJobPod(ns, podName, jobName))
+	source := &reconnectingLogSource{
+		lines: []string{
+			"2024-01-01T10:00:00.100000000Z running tests",
+			"2024-01-01T10:00:00.200000000Z " + job.PodArtifactBeginPrefix + "junit.xml",
+			"2024-01-01T10:00:00.300000000Z " + encoded[:8],
+			"2024-01-01T10:00:00.400000000Z " + encoded[8:],
+			"2024-01-01T10:00:00.500000000Z " + job.PodArtifactEnd,
+			"2024-01-01T10:00:00.600000000Z tests passed",
+		},
+		split: 3,
+		onResume: func(podName string) {
+			completeJob(t, kubeClient, ns, jobName, podName)
+		},
+	}
+
+	dir := t.TempDir()
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = source
+	o.Namespace = ns
+	o.Name = jobName
+	o.ArtifactsDir = dir
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+	assert.Equal(t, 2, source.opens, "should have tailed the pod again")
+
+	data, err := os.ReadFile(filepath.Join(dir, jobName, "junit.xml"))
+	require.NoError(t, err, "should have saved the artifact split across the reconnect")
+	assert.Equal(t, "<testsuites/>\n", string(data), "artifact content")
+	assert.Equal(t, "running tests\ntests passed\n", out.String(), "should hide the artifact from the output")
+}
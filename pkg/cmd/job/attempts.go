@@ -0,0 +1,156 @@
+package job
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// JobAttempt a pod created by a job to run one of its completions
+type JobAttempt struct {
+	// Index the completion index of an Indexed job or an empty string
+	Index string
+	// Attempt the number of the attempt of the completion index starting at 1
+	Attempt   int
+	Pod       string
+	StartTime time.Time
+	EndTime   time.Time
+	ExitCode  *int32
+	Phase     corev1.PodPhase
+}
+
+// getJobAttempts returns the attempts of each completion index of the job in order from its pods sorted by creation
+func (o *Options) getJobAttempts(podItems []corev1.Pod) []JobAttempt {
+	var answer []JobAttempt
+	counts := map[string]int{}
+	for i := range podItems {
+		pod := &podItems[i]
+		index := pod.Annotations[batchv1.JobCompletionIndexAnnotation]
+		counts[index]++
+		a := JobAttempt{
+			Index:   index,
+			Attempt: counts[index],
+			Pod:     pod.Name,
+			Phase:   pod.Status.Phase,
+		}
+		if pod.Status.StartTime != nil {
+			a.StartTime = pod.Status.StartTime.Time
+		}
+		for j := range pod.Status.ContainerStatuses {
+			terminated := pod.Status.ContainerStatuses[j].State.Terminated
+			if terminated == nil {
+				continue
+			}
+			if terminated.FinishedAt.After(a.EndTime) {
+				a.EndTime = terminated.FinishedAt.Time
+			}
+			if a.ExitCode == nil || (*a.ExitCode == 0 && terminated.ExitCode != 0) || pod.Status.ContainerStatuses[j].Name == o.ContainerName {
+				exitCode := terminated.ExitCode
+				a.ExitCode = &exitCode
+			}
+		}
+		answer = append(answer, a)
+	}
+
+	sort.SliceStable(answer, func(i, j int) bool {
+		return indexLess(answer[i].Index, answer[j].Index)
+	})
+	return answer
+}
+
+// indexLess compares completion indexes numerically
+func indexLess(a, b string) bool {
+	ai, aerr := strconv.Atoi(a)
+	bi, berr := strconv.Atoi(b)
+	if aerr == nil && berr == nil {
+		return ai < bi
+	}
+	return a < b
+}
+
+// retriedAttempts returns the successful attempts of each completion index which followed a failed attempt
+func retriedAttempts(attempts []JobAttempt) []JobAttempt {
+	failed := map[string]bool{}
+	var answer []JobAttempt
+	for i := range attempts {
+		a := &attempts[i]
+		switch a.Phase {
+		case corev1.PodFailed:
+			failed[a.Index] = true
+		case corev1.PodSucceeded:
+			if failed[a.Index] {
+				answer = append(answer, *a)
+			}
+		}
+	}
+	return answer
+}
+
+// reportJobAttempts writes a table of the attempts of the job if it has more than one pod or is an Indexed job
+// and warns if the job only succeeded on a retry
+func (o *Options) reportJobAttempts(job *batchv1.Job, attempts []JobAttempt, succeeded bool) {
+	indexed := job.Spec.CompletionMode != nil && *job.Spec.CompletionMode == batchv1.IndexedCompletion
+	if len(attempts) < 2 && !indexed {
+		return
+	}
+
+	// lets write the table in one go as jobs may be verified concurrently
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "\nattempts of job %s:\n", info(job.Name))
+	t := table.CreateTable(buf)
+	t.AddRow("INDEX", "ATTEMPT", "POD", "START", "END", "EXIT CODE", "RESULT")
+	for i := range attempts {
+		a := &attempts[i]
+		index := a.Index
+		if index == "" {
+			index = "-"
+		}
+		exitCode := ""
+		if a.ExitCode != nil {
+			exitCode = strconv.Itoa(int(*a.ExitCode))
+		}
+		t.AddRow(index, strconv.Itoa(a.Attempt), a.Pod, formatAttemptTime(a.StartTime), formatAttemptTime(a.EndTime), exitCode, colorPodPhase(a.Phase))
+	}
+	t.Render()
+	o.lock.Lock()
+	fmt.Fprint(o.Out, buf.String())
+	o.lock.Unlock()
+
+	if !succeeded {
+		return
+	}
+	for _, a := range retriedAttempts(attempts) {
+		if a.Index != "" {
+			logger.Logger().Warnf("job %s completion index %s %s on a retry: attempt %d", job.Name, a.Index, termcolor.ColorWarning("succeeded"), a.Attempt)
+		} else {
+			logger.Logger().Warnf("job %s %s on a retry: attempt %d", job.Name, termcolor.ColorWarning("succeeded"), a.Attempt)
+		}
+	}
+}
+
+func formatAttemptTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func colorPodPhase(phase corev1.PodPhase) string {
+	switch phase {
+	case corev1.PodSucceeded:
+		return info(string(phase))
+	case corev1.PodFailed:
+		return termcolor.ColorError(string(phase))
+	default:
+		return termcolor.ColorWarning(string(phase))
+	}
+}
@@ -0,0 +1,74 @@
+package job_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVerifyJobReportsRetries(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+	created := time.Now().Add(-time.Hour)
+
+	indexedJob := newFinishedJob(ns, "indexed", labels, batchv1.JobComplete)
+	completionMode := batchv1.IndexedCompletion
+	indexedJob.Spec.CompletionMode = &completionMode
+
+	newAttemptPod := func(name, jobName, index string, phase corev1.PodPhase, exitCode int32, offset time.Duration) *corev1.Pod {
+		pod := newJobPod(ns, name, jobName, phase, exitCode)
+		pod.CreationTimestamp = metav1.NewTime(created.Add(offset))
+		if index != "" {
+			pod.Annotations = map[string]string{batchv1.JobCompletionIndexAnnotation: index}
+		}
+		return pod
+	}
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(
+		newFinishedJob(ns, "flaky", labels, batchv1.JobComplete),
+		newAttemptPod("flaky-abc", "flaky", "", corev1.PodFailed, 1, 0),
+		newAttemptPod("flaky-def", "flaky", "", corev1.PodSucceeded, 0, time.Minute),
+		indexedJob,
+		newAttemptPod("indexed-1-abc", "indexed", "1", corev1.PodFailed, 2, 0),
+		newAttemptPod("indexed-0-abc", "indexed", "0", corev1.PodSucceeded, 0, time.Second),
+		newAttemptPod("indexed-1-def", "indexed", "1", corev1.PodSucceeded, 0, time.Minute),
+		newFinishedJob(ns, "stable", labels, batchv1.JobComplete),
+		newAttemptPod("stable-abc", "stable", "", corev1.PodSucceeded, 0, 0),
+	)
+	o.Namespace = ns
+	o.Selector = "app=jx-bdd"
+	o.All = true
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the jobs")
+
+	text := out.String()
+	t.Logf("output:\n%s\n", text)
+	for _, line := range []string{"flaky-abc", "flaky-def", "indexed-0-abc", "indexed-1-abc", "indexed-1-def"} {
+		assert.Contains(t, text, line, "should report attempt %s", line)
+	}
+	assert.NotContains(t, text, "stable-abc", "should not report the attempts of jobs which ran once")
+	assert.Less(t, strings.Index(text, "indexed-0-abc"), strings.Index(text, "indexed-1-abc"), "should sort attempts by index")
+	assert.Less(t, strings.Index(text, "indexed-1-abc"), strings.Index(text, "indexed-1-def"), "should sort attempts by creation")
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "flaky "), strings.HasPrefix(line, "indexed "):
+			assert.Contains(t, line, "Succeeded on retry", "result of retried job")
+		case strings.HasPrefix(line, "stable "):
+			assert.NotContains(t, line, "retry", "result of job which succeeded first time")
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package job
+
+import (
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+const (
+	// CommitShaLabel the label or annotation jx-git-operator adds to the boot jobs with the git commit sha they apply
+	CommitShaLabel = "git-operator.jenkins.io/commit-sha"
+
+	// fullCommitShaLength the length of a commit sha which is not abbreviated
+	fullCommitShaLength = 40
+)
+
+// MatchesCommitSha returns true if the commit sha is empty or the job has a commit sha label or annotation
+// which matches it. The commit sha may be abbreviated
+func MatchesCommitSha(job *batchv1.Job, commitSha string) bool {
+	if commitSha == "" {
+		return true
+	}
+	commitSha = strings.ToLower(commitSha)
+	for _, m := range []map[string]string{job.Labels, job.Annotations} {
+		value := strings.ToLower(m[CommitShaLabel])
+		if value != "" && strings.HasPrefix(value, commitSha) {
+			return true
+		}
+	}
+	return false
+}
+
+// commitShaSelector adds the commit sha label to the selector if the commit sha is not abbreviated so that only the
+// jobs of the commit are listed. Abbreviated commit shas and annotations cannot be selected so are only matched by
+// MatchesCommitSha
+func commitShaSelector(selector, commitSha string) string {
+	if len(commitSha) != fullCommitShaLength {
+		return selector
+	}
+	requirement := CommitShaLabel + "=" + strings.ToLower(commitSha)
+	if selector == "" {
+		return requirement
+	}
+	return selector + "," + requirement
+}
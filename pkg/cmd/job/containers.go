@@ -0,0 +1,238 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/pods"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var prefixColors = []func(a ...interface{}) string{
+	termcolor.ColorInfo,
+	termcolor.ColorStatus,
+	termcolor.ColorAnswer,
+	termcolor.ColorWarning,
+	termcolor.ColorBold,
+}
+
+// prefixWriter writes each complete line to the underlying writer with a prefix. The lock is shared
+// between writers so that lines from different jobs and containers are interleaved but never mixed
+type prefixWriter struct {
+	out    io.Writer
+	prefix string
+	lock   *sync.Mutex
+	buffer []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buffer = append(w.buffer, p...)
+	for {
+		i := bytes.IndexByte(w.buffer, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		err := w.writeLine(w.buffer[:i+1])
+		w.buffer = w.buffer[i+1:]
+		if err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush writes any remaining partial line
+func (w *prefixWriter) Flush() error {
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	line := append(w.buffer, '\n')
+	w.buffer = nil
+	return w.writeLine(line)
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	_, err := fmt.Fprintf(w.out, "%s %s", w.prefix, line)
+	return err
+}
+
+// newPrefixWriter creates a writer to the output which prefixes each line with the given name in the next colour
+func (o *Options) newPrefixWriter(name string) *prefixWriter {
+	o.outLock.Lock()
+	colorFn := prefixColors[o.prefixCount%len(prefixColors)]
+	o.prefixCount++
+	o.outLock.Unlock()
+
+	return &prefixWriter{
+		out:    o.Out,
+		prefix: colorFn("[" + name + "]"),
+		lock:   &o.outLock,
+	}
+}
+
+// tailAllContainers tails the init containers of the pod in order and then all the containers at the same time
+// prefixing each line with the pod and container name
+func (o *Options) tailAllContainers(ctx context.Context, w *jobWatcher, ns string, pod *corev1.Pod) {
+	// sidecar init containers keep running so lets tail them with the other containers
+	var containers []corev1.Container
+	for i := range pod.Spec.InitContainers {
+		c := &pod.Spec.InitContainers[i]
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			containers = append(containers, *c)
+		}
+	}
+	containers = append(containers, pod.Spec.Containers...)
+
+	for i := range pod.Spec.InitContainers {
+		c := &pod.Spec.InitContainers[i]
+		if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			continue
+		}
+		containerName := c.Name
+		status, err := o.waitForContainerToStart(ctx, w, pod.Name, containerName, true)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Logger().Warnf("failed to wait for init container %s of pod %s: %s", containerName, pod.Name, err.Error())
+			}
+			return
+		}
+		if status == nil {
+			return
+		}
+		o.tailContainer(ctx, ns, w.jobName, pod.Name, containerName, o.newPrefixWriter(pod.Name+"/"+containerName))
+
+		// if the init container failed the remaining containers will never start
+		status, err = o.waitForContainerToStop(ctx, w, pod.Name, containerName, true)
+		if err != nil {
+			if ctx.Err() == nil {
+				logger.Logger().Warnf("failed to wait for init container %s of pod %s: %s", containerName, pod.Name, err.Error())
+			}
+			return
+		}
+		if status != nil && status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+			logger.Logger().Infof("init container %s of pod %s %s with exit code %d", info(containerName), info(pod.Name),
+				termcolor.ColorError("failed"), status.State.Terminated.ExitCode)
+			return
+		}
+	}
+
+	// sidecars may keep running after the main container so lets stop tailing them once it terminates
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wg := sync.WaitGroup{}
+	for i := range containers {
+		containerName := containers[i].Name
+		initContainer := i < len(containers)-len(pod.Spec.Containers)
+		out := o.newPrefixWriter(pod.Name + "/" + containerName)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if containerName == o.MainContainer && !initContainer {
+				defer cancel()
+			}
+
+			status, err := o.waitForContainerToStart(ctx, w, pod.Name, containerName, initContainer)
+			if err != nil {
+				if ctx.Err() == nil {
+					logger.Logger().Warnf("failed to wait for container %s of pod %s: %s", containerName, pod.Name, err.Error())
+				}
+				return
+			}
+			if status != nil {
+				o.tailContainer(ctx, ns, w.jobName, pod.Name, containerName, out)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func (o *Options) tailContainer(ctx context.Context, ns, jobName, podName, containerName string, out *prefixWriter) {
+	artifacts := o.newArtifactWriter(out, jobName, podName)
+	err := o.tailLogs(ctx, ns, podName, containerName, artifacts)
+	if err != nil && ctx.Err() == nil {
+		logger.Logger().Warnf("failed to tail log of container %s: %s", containerName, err.Error())
+	}
+	err = artifacts.Flush()
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		logger.Logger().Warnf("failed to write log of container %s: %s", containerName, err.Error())
+	}
+}
+
+// waitForContainerToStart waits for the container to be running or terminated returning its status
+// or nil if the pod completes or is removed without the container starting
+func (o *Options) waitForContainerToStart(ctx context.Context, w *jobWatcher, podName, containerName string, initContainer bool) (*corev1.ContainerStatus, error) {
+	return o.waitForContainer(ctx, w, podName, containerName, initContainer, func(s *corev1.ContainerStatus) bool {
+		return s.State.Running != nil || s.State.Terminated != nil
+	})
+}
+
+// waitForContainerToStop waits for the container to terminate returning its status
+// or nil if the pod completes or is removed without the container terminating
+func (o *Options) waitForContainerToStop(ctx context.Context, w *jobWatcher, podName, containerName string, initContainer bool) (*corev1.ContainerStatus, error) {
+	return o.waitForContainer(ctx, w, podName, containerName, initContainer, func(s *corev1.ContainerStatus) bool {
+		return s.State.Terminated != nil
+	})
+}
+
+func (o *Options) waitForContainer(ctx context.Context, w *jobWatcher, podName, containerName string, initContainer bool, fn func(*corev1.ContainerStatus) bool) (*corev1.ContainerStatus, error) {
+	for {
+		pod, err := w.GetPod(podName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod %s: %w", podName, err)
+		}
+		if pod == nil {
+			return nil, nil
+		}
+		status := findContainerStatus(pod, containerName, initContainer)
+		if status != nil && fn(status) {
+			return status, nil
+		}
+		if pods.IsPodCompleted(pod) || pod.DeletionTimestamp != nil {
+			return nil, nil
+		}
+		if time.Now().After(o.timeEnd) {
+			return nil, fmt.Errorf("timed out after waiting for duration %s", o.Duration.String())
+		}
+		err = w.Wait(ctx, o.PollPeriod)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func findContainerStatus(pod *corev1.Pod, containerName string, initContainer bool) *corev1.ContainerStatus {
+	statuses := pod.Status.ContainerStatuses
+	if initContainer {
+		statuses = pod.Status.InitContainerStatuses
+	}
+	for i := range statuses {
+		if statuses[i].Name == containerName {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// hasStartedInitContainer returns true if any init container of the pod is running or has terminated
+func hasStartedInitContainer(pod *corev1.Pod) bool {
+	for i := range pod.Status.InitContainerStatuses {
+		s := &pod.Status.InitContainerStatuses[i]
+		if s.State.Running != nil || s.State.Terminated != nil {
+			return true
+		}
+	}
+	return false
+}
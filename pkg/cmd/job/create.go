@@ -0,0 +1,248 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/naming"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// CleanupAlways always delete the jobs created by the command
+	CleanupAlways = "always"
+
+	// CleanupOnSuccess only delete the jobs created by the command if they succeed
+	CleanupOnSuccess = "on-success"
+
+	// CleanupNever never delete the jobs created by the command
+	CleanupNever = "never"
+
+	// maxJobNameLength the maximum length of a job name so that the pod names are valid labels
+	maxJobNameLength = 52
+
+	// deleteOnCancelTimeout how long to wait to delete a job when the command is cancelled
+	deleteOnCancelTimeout = 30 * time.Second
+)
+
+// CleanupValues the valid values of the cleanup option
+var CleanupValues = []string{CleanupAlways, CleanupOnSuccess, CleanupNever}
+
+// createJobFromCronJob creates a new job from the job template of the given CronJob
+func (o *Options) createJobFromCronJob(ctx context.Context, client kubernetes.Interface, ns, cronJobName string) (*batchv1.Job, error) {
+	cronJob, err := client.BatchV1().CronJobs(ns).Get(ctx, cronJobName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get CronJob %s in namespace %s: %w", cronJobName, ns, err)
+	}
+
+	template := &cronJob.Spec.JobTemplate
+	annotations := map[string]string{
+		"cronjob.kubernetes.io/instantiate": "manual",
+	}
+	for k, v := range template.Annotations {
+		annotations[k] = v
+	}
+	labels := map[string]string{}
+	for k, v := range template.Labels {
+		labels[k] = v
+	}
+
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        toUniqueJobName(cronJob.Name + "-manual"),
+			Namespace:   ns,
+			Labels:      labels,
+			Annotations: annotations,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	job, err = client.BatchV1().Jobs(ns).Create(ctx, job, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Job from CronJob %s in namespace %s: %w", cronJobName, ns, err)
+	}
+	logger.Logger().Infof("created Job %s from CronJob %s in namespace %s", info(job.Name), info(cronJobName), info(ns))
+	return job, nil
+}
+
+// createJobsFromFiles creates the jobs defined in the manifest files. All the files are loaded before any job is
+// created and if a job cannot be created the jobs already created are deleted so none are left running unverified
+func (o *Options) createJobsFromFiles(ctx context.Context, client kubernetes.Interface, ns string) ([]batchv1.Job, error) {
+	var jobs []batchv1.Job
+	var jobFiles []string
+	for _, f := range o.Files {
+		jobList, err := loadJobManifests(f)
+		if err != nil {
+			return nil, err
+		}
+		for i := range jobList {
+			job := &jobList[i]
+			job.Namespace = ns
+			if job.Name == "" && job.GenerateName != "" {
+				job.Name = toUniqueJobName(strings.TrimSuffix(job.GenerateName, "-"))
+			} else if o.GenerateSuffix {
+				job.Name = toUniqueJobName(job.Name)
+			}
+			if job.Name == "" {
+				return nil, fmt.Errorf("job in file %s has no name", f)
+			}
+			jobs = append(jobs, *job)
+			jobFiles = append(jobFiles, f)
+		}
+	}
+
+	var answer []batchv1.Job
+	for i := range jobs {
+		f := jobFiles[i]
+		job, err := client.BatchV1().Jobs(ns).Create(ctx, &jobs[i], metav1.CreateOptions{})
+		if err != nil {
+			for j := range answer {
+				o.deleteUnverifiedJob(ctx, client, ns, answer[j].Name)
+			}
+			return nil, fmt.Errorf("failed to create Job %s from file %s in namespace %s: %w", jobs[i].Name, f, ns, err)
+		}
+		logger.Logger().Infof("created Job %s from file %s in namespace %s", info(job.Name), info(f), info(ns))
+		answer = append(answer, *job)
+	}
+	return answer, nil
+}
+
+// deleteUnverifiedJob deletes a job created from a file which will not be verified as another job could not be created
+func (o *Options) deleteUnverifiedJob(ctx context.Context, client kubernetes.Interface, ns, jobName string) {
+	propagationPolicy := metav1.DeletePropagationBackground
+	err := client.BatchV1().Jobs(ns).Delete(ctx, jobName, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+	if err != nil {
+		logger.Logger().Warnf("failed to delete Job %s in namespace %s: %s", jobName, ns, err.Error())
+		return
+	}
+	logger.Logger().Infof("deleted Job %s in namespace %s as it will not be verified", info(jobName), info(ns))
+}
+
+// loadJobManifests loads the jobs from the given YAML or JSON file which may contain multiple documents
+func loadJobManifests(fileName string) ([]batchv1.Job, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fileName, err)
+	}
+	defer f.Close()
+
+	var answer []batchv1.Job
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		job := batchv1.Job{}
+		err = decoder.Decode(&job)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return answer, fmt.Errorf("failed to parse file %s: %w", fileName, err)
+		}
+		if job.Kind == "" && job.Name == "" && job.GenerateName == "" {
+			// ignore empty documents
+			continue
+		}
+		if job.Kind != "Job" {
+			return answer, fmt.Errorf("file %s contains a %s %s but only Job resources are supported", fileName, job.Kind, job.Name)
+		}
+		answer = append(answer, job)
+	}
+	if len(answer) == 0 {
+		return nil, fmt.Errorf("no Job resources found in file %s", fileName)
+	}
+	return answer, nil
+}
+
+// toUniqueJobName returns a valid job name with a random suffix
+func toUniqueJobName(name string) string {
+	suffix := "-" + rand.String(5)
+	return naming.ToValidNameTruncated(name, maxJobNameLength-len(suffix)) + suffix
+}
+
+// cleanupJob deletes a job created by the command depending on the cleanup option and the verification error
+func (o *Options) cleanupJob(ctx context.Context, client kubernetes.Interface, ns, jobName string, verifyErr error) {
+	if ctx.Err() != nil {
+		// the job is only deleted on cancel by --delete-on-cancel
+		return
+	}
+	switch o.Cleanup {
+	case CleanupAlways:
+	case CleanupOnSuccess:
+		if verifyErr != nil {
+			logger.Logger().Infof("not deleting Job %s as it failed", info(jobName))
+			return
+		}
+	default:
+		return
+	}
+
+	propagationPolicy := metav1.DeletePropagationBackground
+	err := client.BatchV1().Jobs(ns).Delete(ctx, jobName, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+	if err != nil {
+		logger.Logger().Warnf("failed to delete Job %s in namespace %s: %s", jobName, ns, err.Error())
+		return
+	}
+	logger.Logger().Infof("deleted Job %s in namespace %s", info(jobName), info(ns))
+}
+
+// deleteCancelledJob deletes the job when the command is cancelled and waits up to deleteOnCancelTimeout for its
+// pods to be removed so that they are not left running after the command exits
+func (o *Options) deleteCancelledJob(client kubernetes.Interface, ns, jobName string) {
+	// the command context has been cancelled so lets use a new one
+	ctx, cancel := context.WithTimeout(context.Background(), deleteOnCancelTimeout)
+	defer cancel()
+
+	propagationPolicy := metav1.DeletePropagationForeground
+	err := client.BatchV1().Jobs(ns).Delete(ctx, jobName, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+	if err != nil {
+		logger.Logger().Warnf("failed to delete cancelled Job %s in namespace %s: %s", jobName, ns, err.Error())
+		return
+	}
+	err = o.waitForJobPodsToBeRemoved(ctx, client, ns, jobName)
+	if err != nil {
+		logger.Logger().Warnf("deleted cancelled Job %s in namespace %s but its pods may still be running: %s", jobName, ns, err.Error())
+		return
+	}
+	logger.Logger().Infof("deleted cancelled Job %s in namespace %s", info(jobName), info(ns))
+}
+
+// waitForJobPodsToBeRemoved waits until there are no pods of the job or the context is done
+func (o *Options) waitForJobPodsToBeRemoved(ctx context.Context, client kubernetes.Interface, ns, jobName string) error {
+	selector := "job-name=" + jobName
+	for {
+		podList, err := client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list pods in namespace %s with selector %s: %w", ns, selector, err)
+		}
+		if len(podList.Items) == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(o.PollPeriod)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("timed out waiting for %d pods to be removed: %w", len(podList.Items), ctx.Err())
+		}
+	}
+}
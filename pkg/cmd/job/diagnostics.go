@@ -0,0 +1,251 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// maxDiagnosisEvents the maximum number of recent warning events to include in a diagnosis
+const maxDiagnosisEvents = 20
+
+// JobDiagnosis the information gathered to help diagnose why a job failed
+type JobDiagnosis struct {
+	Job        string                 `json:"job"`
+	Namespace  string                 `json:"namespace"`
+	Conditions []batchv1.JobCondition `json:"conditions,omitempty"`
+	Pods       []PodDiagnosis         `json:"pods,omitempty"`
+	Events     []EventDiagnosis       `json:"events,omitempty"`
+}
+
+// PodDiagnosis the status of a pod of a failed job
+type PodDiagnosis struct {
+	Name       string               `json:"name"`
+	Phase      corev1.PodPhase      `json:"phase"`
+	Reason     string               `json:"reason,omitempty"`
+	Message    string               `json:"message,omitempty"`
+	Containers []ContainerDiagnosis `json:"containers,omitempty"`
+}
+
+// ContainerDiagnosis the status of a container of a failed job
+type ContainerDiagnosis struct {
+	Name         string `json:"name"`
+	Init         bool   `json:"init,omitempty"`
+	State        string `json:"state"`
+	ExitCode     *int32 `json:"exitCode,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	Message      string `json:"message,omitempty"`
+	OOMKilled    bool   `json:"oomKilled,omitempty"`
+	RestartCount int32  `json:"restartCount,omitempty"`
+}
+
+// EventDiagnosis a warning event for a failed job or its pods
+type EventDiagnosis struct {
+	Kind          string      `json:"kind"`
+	Name          string      `json:"name"`
+	Reason        string      `json:"reason,omitempty"`
+	Message       string      `json:"message,omitempty"`
+	Count         int32       `json:"count,omitempty"`
+	LastTimestamp metav1.Time `json:"lastTimestamp,omitempty"`
+}
+
+// diagnoseJob gathers the information to diagnose why the job failed from the job, its pods sorted by creation and
+// their events. If the events cannot be listed, which is often forbidden by RBAC, the partial diagnosis is returned
+// with the error
+func diagnoseJob(ctx context.Context, client kubernetes.Interface, ns string, job *batchv1.Job, podItems []corev1.Pod) (*JobDiagnosis, error) {
+	answer := &JobDiagnosis{
+		Job:        job.Name,
+		Namespace:  ns,
+		Conditions: job.Status.Conditions,
+	}
+
+	objects := map[string]string{
+		job.Name: "Job",
+	}
+	for i := range podItems {
+		pod := &podItems[i]
+		objects[pod.Name] = "Pod"
+		pd := PodDiagnosis{
+			Name:    pod.Name,
+			Phase:   pod.Status.Phase,
+			Reason:  pod.Status.Reason,
+			Message: pod.Status.Message,
+		}
+		for j := range pod.Status.InitContainerStatuses {
+			pd.Containers = append(pd.Containers, toContainerDiagnosis(&pod.Status.InitContainerStatuses[j], true))
+		}
+		for j := range pod.Status.ContainerStatuses {
+			pd.Containers = append(pd.Containers, toContainerDiagnosis(&pod.Status.ContainerStatuses[j], false))
+		}
+		answer.Pods = append(answer.Pods, pd)
+	}
+
+	var eventsErr error
+	for name, kind := range objects {
+		eventList, err := client.CoreV1().Events(ns).List(ctx, metav1.ListOptions{
+			FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s,type=%s", kind, name, corev1.EventTypeWarning),
+		})
+		if err != nil {
+			// lets not repeat the same failure for each object
+			eventsErr = fmt.Errorf("failed to list events in namespace %s for %s %s: %w", ns, kind, name, err)
+			break
+		}
+		for i := range eventList.Items {
+			e := &eventList.Items[i]
+			if e.Type != corev1.EventTypeWarning || e.InvolvedObject.Kind != kind || e.InvolvedObject.Name != name {
+				continue
+			}
+			last := e.LastTimestamp
+			if last.IsZero() {
+				last = metav1.NewTime(e.EventTime.Time)
+			}
+			answer.Events = append(answer.Events, EventDiagnosis{
+				Kind:          kind,
+				Name:          name,
+				Reason:        e.Reason,
+				Message:       e.Message,
+				Count:         e.Count,
+				LastTimestamp: last,
+			})
+		}
+	}
+
+	// lets keep the most recent events in time order
+	sort.Slice(answer.Events, func(i, j int) bool {
+		return answer.Events[i].LastTimestamp.Before(&answer.Events[j].LastTimestamp)
+	})
+	if len(answer.Events) > maxDiagnosisEvents {
+		answer.Events = answer.Events[len(answer.Events)-maxDiagnosisEvents:]
+	}
+	return answer, eventsErr
+}
+
+func toContainerDiagnosis(s *corev1.ContainerStatus, initContainer bool) ContainerDiagnosis {
+	answer := ContainerDiagnosis{
+		Name:         s.Name,
+		Init:         initContainer,
+		RestartCount: s.RestartCount,
+	}
+	terminated := s.State.Terminated
+	switch {
+	case s.State.Running != nil:
+		answer.State = "Running"
+		terminated = s.LastTerminationState.Terminated
+	case s.State.Waiting != nil:
+		answer.State = "Waiting"
+		answer.Reason = s.State.Waiting.Reason
+		answer.Message = s.State.Waiting.Message
+		terminated = s.LastTerminationState.Terminated
+	case terminated != nil:
+		answer.State = "Terminated"
+	}
+	if terminated != nil {
+		exitCode := terminated.ExitCode
+		answer.ExitCode = &exitCode
+		if answer.Reason == "" {
+			answer.Reason = terminated.Reason
+			answer.Message = terminated.Message
+		}
+		answer.OOMKilled = terminated.Reason == "OOMKilled"
+	}
+	return answer
+}
+
+// String returns a readable description of the diagnosis
+func (d *JobDiagnosis) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "\ndiagnosis of job %s in namespace %s:\n", info(d.Job), info(d.Namespace))
+	for i := range d.Conditions {
+		c := &d.Conditions[i]
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		fmt.Fprintf(sb, "  condition %s: %s %s\n", termcolor.ColorWarning(string(c.Type)), c.Reason, c.Message)
+	}
+	for i := range d.Pods {
+		p := &d.Pods[i]
+		fmt.Fprintf(sb, "  pod %s is %s", info(p.Name), p.Phase)
+		if p.Reason != "" {
+			fmt.Fprintf(sb, " reason %s: %s", p.Reason, p.Message)
+		}
+		sb.WriteString("\n")
+		for j := range p.Containers {
+			c := &p.Containers[j]
+			kind := "container"
+			if c.Init {
+				kind = "init container"
+			}
+			fmt.Fprintf(sb, "    %s %s is %s", kind, info(c.Name), c.State)
+			if c.ExitCode != nil {
+				exitCode := fmt.Sprintf("%d", *c.ExitCode)
+				if *c.ExitCode != 0 {
+					exitCode = termcolor.ColorError(exitCode)
+				}
+				fmt.Fprintf(sb, " exit code %s", exitCode)
+			}
+			if c.Reason != "" {
+				fmt.Fprintf(sb, " reason %s", c.Reason)
+			}
+			if c.OOMKilled {
+				sb.WriteString(termcolor.ColorError(" (OOMKilled)"))
+			}
+			if c.RestartCount > 0 {
+				fmt.Fprintf(sb, " restarts %d", c.RestartCount)
+			}
+			if c.Message != "" {
+				fmt.Fprintf(sb, ": %s", strings.TrimSpace(c.Message))
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if len(d.Events) > 0 {
+		sb.WriteString("  warning events:\n")
+		for i := range d.Events {
+			e := &d.Events[i]
+			fmt.Fprintf(sb, "    %s %s %s: %s", e.Kind, info(e.Name), termcolor.ColorWarning(e.Reason), e.Message)
+			if e.Count > 1 {
+				fmt.Fprintf(sb, " (x%d)", e.Count)
+			}
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// reportJobDiagnosis diagnoses the failed job, writing the diagnosis to the output and optionally saving it as YAML.
+// A partial diagnosis is still reported with a warning about the information which could not be gathered
+func (o *Options) reportJobDiagnosis(ctx context.Context, client kubernetes.Interface, ns string, job *batchv1.Job, podItems []corev1.Pod) error {
+	diagnosis, err := diagnoseJob(ctx, client, ns, job, podItems)
+	if err != nil {
+		logger.Logger().Warnf("the diagnosis of job %s is incomplete: %s", job.Name, err.Error())
+	}
+	fmt.Fprint(o.Out, diagnosis.String())
+
+	if o.DiagnosticsDir == "" {
+		return nil
+	}
+	err = os.MkdirAll(o.DiagnosticsDir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", o.DiagnosticsDir, err)
+	}
+	fileName := filepath.Join(o.DiagnosticsDir, job.Name+".yaml")
+	err = yamls.SaveFile(diagnosis, fileName)
+	if err != nil {
+		return fmt.Errorf("failed to save diagnosis: %w", err)
+	}
+	logger.Logger().Infof("saved diagnosis of job %s to %s", info(job.Name), info(fileName))
+	return nil
+}
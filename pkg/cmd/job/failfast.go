@@ -0,0 +1,75 @@
+package job
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// unrecoverableReasons the reasons a container cannot start which will never fix themselves
+	unrecoverableReasons = map[string]bool{
+		"InvalidImageName":  true,
+		"ErrImageNeverPull": true,
+	}
+
+	// stuckReasons the reasons a container cannot start which may fix themselves such as when the image is pushed
+	// or a missing Secret or ConfigMap is created so we only fail once the grace period has expired
+	stuckReasons = map[string]bool{
+		"ErrImagePull":               true,
+		"ImagePullBackOff":           true,
+		"CreateContainerConfigError": true,
+		"CreateContainerError":       true,
+	}
+)
+
+// podStuckReason returns a description of why the pod can never start or has been unable to start for longer than
+// the grace period or an empty string if the pod is not stuck
+func podStuckReason(pod *corev1.Pod, grace time.Duration, now time.Time) string {
+	if pod.Status.Phase != corev1.PodPending || pod.DeletionTimestamp != nil {
+		return ""
+	}
+
+	for i := range pod.Status.Conditions {
+		c := &pod.Status.Conditions[i]
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+			since := c.LastTransitionTime.Time
+			if since.IsZero() {
+				since = pod.CreationTimestamp.Time
+			}
+			if now.Sub(since) >= grace {
+				return fmt.Sprintf("pod is %s: %s", c.Reason, c.Message)
+			}
+			return ""
+		}
+	}
+
+	// container statuses have no timestamp for waiting so lets use the time the pod started
+	since := pod.CreationTimestamp.Time
+	if pod.Status.StartTime != nil {
+		since = pod.Status.StartTime.Time
+	}
+	expired := now.Sub(since) >= grace
+	statuses := map[bool][]corev1.ContainerStatus{
+		true:  pod.Status.InitContainerStatuses,
+		false: pod.Status.ContainerStatuses,
+	}
+	for _, initContainer := range []bool{true, false} {
+		for i := range statuses[initContainer] {
+			s := &statuses[initContainer][i]
+			if s.State.Waiting == nil {
+				continue
+			}
+			reason := s.State.Waiting.Reason
+			if unrecoverableReasons[reason] || (expired && stuckReasons[reason]) {
+				kind := "container"
+				if initContainer {
+					kind = "init container"
+				}
+				return fmt.Sprintf("%s %s is waiting with reason %s: %s", kind, s.Name, reason, s.State.Waiting.Message)
+			}
+		}
+	}
+	return ""
+}
@@ -2,23 +2,27 @@ package job
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/podlogs"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/signals"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/input/inputfactory"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/jobs"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/podlogs"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/pods"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
 	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -36,22 +40,57 @@ import (
 type Options struct {
 	options.BaseOptions
 
-	Namespace     string
-	Name          string
-	Selector      string
-	FieldSelector string
-	ContainerName string
-	Duration      time.Duration
-	PollPeriod    time.Duration
-	NoTail        bool
-	LogFail       bool
-	VerifyResult  bool
-	ErrOut        io.Writer
-	Out           io.Writer
-	KubeClient    kubernetes.Interface
-	Input         input.Interface
-	timeEnd       time.Time
-	podStatusMap  map[string]string
+	Namespace        string
+	SearchNamespaces []string
+	Name             string
+	FromCronJob      string
+	Cleanup          string
+	DeleteOnCancel   bool
+	Files            []string
+	GenerateSuffix   bool
+	Selector         string
+	CommitSha        string
+	FieldSelector    string
+	ContainerName    string
+	MainContainer    string
+	StopSidecars     string
+	AllContainers    bool
+	Duration         time.Duration
+	PollPeriod       time.Duration
+	Grace            time.Duration
+	Heartbeat        time.Duration
+	NoTail           bool
+	LogFail          bool
+	VerifyResult     bool
+	All              bool
+	Active           bool
+	Latest           bool
+	Index            *int
+	JUnitFile        string
+	LogDir           string
+	LogArchive       string
+	DiagnosticsDir   string
+	ArtifactsDir     string
+	ExpectLogs       []string
+	FailOnLogs       []string
+	ErrOut           io.Writer
+	Out              io.Writer
+	KubeClient       kubernetes.Interface
+	LogSource        podlogs.LogSource
+	PortForwarder    PortForwarder
+	Input            input.Interface
+	timeEnd          time.Time
+	podStatusMap     map[string]string
+	junitSuites      []junitTestSuite
+	logDir           string
+	logAssertions    *logAssertions
+	logPositions     map[string]*logPosition
+	lock             sync.Mutex
+	prefixJobs       bool
+	prefixCount      int
+	outLock          sync.Mutex
+	lastOutput       atomic.Int64
+	index            int
 }
 
 const (
@@ -71,6 +110,17 @@ var (
 	cmdLong = templates.LongDesc(`
 		Verifies that the job(s) with the given label succeeds and tails the log as it executes
 
+		When using --verify-result the pod reports its result by logging a line starting with 'POD RESULT: ' followed by either 'OK' or 'FAILED: ' and a message.
+
+		Pods can also report individual tests by logging lines starting with 'POD RESULT JSON: ' followed by JSON such as {"name": "my-test", "status": "failed", "duration": 1.5, "message": "expected 1 but was 2"} or a summary of the counts such as {"passed": 10, "failed": 0, "skipped": 2}. A test with a status other than passed, failed or skipped, or a common alias of them such as ok or error, counts as failed. TAP output is also supported if the pod logs a TAP plan line such as '1..10'. The pod fails if it does not run as many tests as its TAP plan or summary says or if it logs a TAP 'Bail out!' line.
+
+		The result can also be written to the termination message of the container, /dev/termination-log by default, using the same format though the 'POD RESULT: ' and 'POD RESULT JSON: ' prefixes are optional. The log is only scanned for the result if there is no termination message.
+
+		The job fails if any test fails.
+
+		The log of the last pod can also be checked with --expect-log and --fail-on-log regular expressions so that a job which exits successfully but did not run its tests still fails.
+
+		Pods can hand back files such as reports or screenshots without exec access or shared storage by logging a 'POD ARTIFACT BEGIN name' line followed by the base64 encoded file over one or more lines and then a 'POD ARTIFACT END' line. The files are saved in the --artifacts-dir directory and the base64 lines are not displayed. The artifacts of pods which completed before they could be tailed are saved from their whole log.
 `)
 
 	cmdExample = templates.Examples(`
@@ -79,6 +129,45 @@ var (
 
 		# verify the BDD job succeeds using name
 		jx verify job --name jx-bdd
+
+		# verify all the BDD jobs succeed
+		jx verify job -l app=jx-bdd --all
+
+		# verify the most recent BDD job succeeds without prompting
+		jx verify job -l app=jx-bdd --latest
+
+		# verify the boot job for the commit we just pushed to the cluster git repository succeeds
+		jx verify job -l app=jx-boot --commit-sha $(git rev-parse HEAD)
+
+		# wait for the next BDD job to be created and verify it succeeds
+		jx verify job -l app=jx-bdd --active
+
+		# verify the BDD job succeeds tailing the logs of all its containers
+		jx verify job --name jx-bdd --all-containers
+
+		# verify the BDD job succeeds and write a JUnit report
+		jx verify job --name jx-bdd --junit junit.xml
+
+		# verify the BDD job succeeds and archive the logs of all the pods
+		jx verify job --name jx-bdd --log-archive logs.tar.gz
+
+		# create a Job from a CronJob and verify it succeeds, deleting the Job afterwards
+		jx verify job --from-cronjob my-cronjob --cleanup always
+
+		# verify the job log contains a summary line and no skipped tests
+		jx verify job --name my-job --expect-log 'tests passed' --fail-on-log 'SKIPPED'
+
+		# verify a job deleting it if the command is cancelled such as when a CI pipeline is aborted
+		jx verify job --name my-job --delete-on-cancel
+
+		# verify a job in an istio meshed namespace using the result of the test container and then stop the istio sidecar
+		jx verify job --name my-job --main-container test --stop-sidecars istio
+
+		# verify a job saving any files it logs as artifacts
+		jx verify job --name my-job --artifacts-dir artifacts
+
+		# create the Jobs in a file and verify they succeed, deleting them if they succeed
+		jx verify job --file job.yaml --generate-suffix --cleanup on-success
 `)
 )
 
@@ -91,53 +180,144 @@ func NewCmdVerifyJob() (*cobra.Command, *Options) {
 		Aliases: []string{"logs"},
 		Long:    cmdLong,
 		Example: cmdExample,
-		Run: func(_ *cobra.Command, _ []string) {
-			err := options.Run()
-			helper.CheckErr(err)
+		Run: func(cmd *cobra.Command, _ []string) {
+			// lets only select by index if the flag is given as 0 is a valid index
+			if cmd.Flags().Changed("index") {
+				options.Index = &options.index
+			}
+			signals.RunUntilSignalled(options.RunWithContext)
 		},
 	}
-	command.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "the namespace where the jobs run. If not specified it will look in: jx-git-operator and jx")
+	command.Flags().StringVarP(&options.Namespace, "namespace", "n", "", "the namespace where the jobs run. If not specified it will look in the --search-namespace namespaces in order and then the current namespace")
+	command.Flags().StringArrayVarP(&options.SearchNamespaces, "search-namespace", "", DefaultSearchNamespaces, "the namespaces to look for the jobs in, in order, if no --namespace is specified. If the Job for the --commit-sha has not been created yet we wait for it in the first namespace with jobs matching the --selector. Can be specified multiple times")
 	command.Flags().StringVarP(&options.Name, "name", "", "", "the name of the job to use")
 	command.Flags().StringVarP(&options.Selector, "selector", "l", "", "the selector of the job pods")
 	command.Flags().StringVarP(&options.FieldSelector, "field-selector", "f", "", "the field selector to use to query jobs")
+	command.Flags().StringVarP(&options.CommitSha, "commit-sha", "", "", "only verify the job for the given git commit sha, or a prefix of it, using the "+CommitShaLabel+" label or annotation added by jx-git-operator. Waits for the job to be created if it does not exist yet")
 	command.Flags().StringVarP(&options.ContainerName, "container", "c", "", "the name of the container in the job to log")
+	command.Flags().StringVarP(&options.MainContainer, "main-container", "", "", "the name of the container which determines the result of the job so that running sidecars do not stop the job from completing. Defaults the --container option")
+	command.Flags().StringVarP(&options.StopSidecars, "stop-sidecars", "", StopSidecarsNone, "how to stop the sidecars once the --main-container has terminated. istio port forwards to the istio agent to call its /quitquitquit endpoint. delete-pod deletes the pod and, unless the Job will retry a failed main container, suspends the Job first so that it does not run the pod again. Values: "+strings.Join(StopSidecarsValues, ", "))
+	command.Flags().BoolVarP(&options.AllContainers, "all-containers", "", false, "tails the init containers in order and then all the containers of the job pods prefixing each line with the pod and container name")
 	command.Flags().DurationVarP(&options.Duration, "duration", "d", time.Minute*60, "how long to wait for a Job to be active and a Pod to be ready")
-	command.Flags().DurationVarP(&options.PollPeriod, "poll", "", time.Second*1, "duration between polls for an active Job or Pod")
+	command.Flags().DurationVarP(&options.PollPeriod, "poll", "", time.Second*1, "the resync period of the Job and Pod watches. Changes are detected as they happen so this is only a fallback")
+	command.Flags().DurationVarP(&options.Grace, "grace", "", time.Minute*2, "how long to wait for a pod which cannot pull its image, create its containers or be scheduled before failing. States which can never recover such as an invalid image name fail straight away")
+	command.Flags().DurationVarP(&options.Heartbeat, "heartbeat", "", time.Minute, "how long the log of the job can be quiet before we log what the job is currently doing so that CI systems do not kill a quiet step. Use 0 to disable")
 	command.Flags().BoolVarP(&options.LogFail, "log-fail", "", false, "rather than failing the command lets just log that the job failed. e.g. this lets us run tests inside a Terraform Pod without the terraform operator thinking the terraform failed.")
-	command.Flags().BoolVarP(&options.VerifyResult, "verify-result", "", false, "if the pod succeeds lets look for the last line starting with "+PodResultPrefix+" to determine the test result")
+	command.Flags().BoolVarP(&options.VerifyResult, "verify-result", "", false, "if the pod succeeds lets look for the result in the container termination message or the last line of the log starting with "+PodResultPrefix+" along with any "+PodResultJSONPrefix+" or TAP lines to determine the test result")
+	command.Flags().BoolVarP(&options.All, "all", "", false, "verifies all the jobs matching the selector concurrently rather than picking a single job. Each line of their logs is prefixed with the job and pod name")
+	command.Flags().BoolVarP(&options.Active, "active", "", false, "waits for the next job matching the selector to be created after the command starts and verifies it")
+	command.Flags().BoolVarP(&options.Latest, "latest", "", false, "verifies the most recently created job matching the selector without prompting")
+	command.Flags().IntVarP(&options.index, "index", "", 0, "verifies the job at the given index of the jobs matching the selector sorted by creation time without prompting. 0 is the most recently created job")
+	command.Flags().StringVarP(&options.JUnitFile, "junit", "", "", "the file name to write a JUnit XML report of the verified jobs")
+	command.Flags().StringVarP(&options.LogDir, "log-dir", "", "", "the directory to save the logs of every pod attempt and container of the verified jobs along with a "+LogManifestFileName+" file")
+	command.Flags().StringVarP(&options.LogArchive, "log-archive", "", "", "the tar.gz file to save the logs of every pod attempt and container of the verified jobs along with a "+LogManifestFileName+" file")
+	command.Flags().StringVarP(&options.DiagnosticsDir, "diagnostics-dir", "", "", "the directory to save the diagnosis of any failed job as a YAML file")
+	command.Flags().StringVarP(&options.ArtifactsDir, "artifacts-dir", "", "", "the directory to save any files the pods write to their log between '"+PodArtifactBeginPrefix+"name' and '"+PodArtifactEnd+"' lines as base64. The files are saved in a directory per job and the base64 lines are not displayed")
+	command.Flags().StringArrayVarP(&options.ExpectLogs, "expect-log", "", nil, "a regex which must match at least one line of the log of the last pod of the job. Can be specified multiple times")
+	command.Flags().StringArrayVarP(&options.FailOnLogs, "fail-on-log", "", nil, "a regex which fails the job if it matches any line of the log of the last pod of the job. Can be specified multiple times")
+	command.Flags().StringVarP(&options.FromCronJob, "from-cronjob", "", "", "the name of a CronJob to create a new Job from which is then verified")
+	command.Flags().StringArrayVarP(&options.Files, "file", "", nil, "the file(s) containing Job manifests to create in the namespace and then verify")
+	command.Flags().BoolVarP(&options.GenerateSuffix, "generate-suffix", "", false, "adds a generated suffix to the names of the Jobs created from the --file manifests so they are unique")
+	command.Flags().StringVarP(&options.Cleanup, "cleanup", "", CleanupNever, "whether to delete any Job created by this command after it has been verified. Values: "+strings.Join(CleanupValues, ", "))
+	command.Flags().BoolVarP(&options.DeleteOnCancel, "delete-on-cancel", "", false, "if the command is cancelled by SIGINT or SIGTERM then delete the Job being verified along with its pods")
 
 	options.BaseOptions.AddBaseFlags(command)
 
 	return command, options
 }
 
+// Run runs the command
 func (o *Options) Run() error {
-	err := o.Validate()
+	return o.RunWithContext(context.Background())
+}
+
+// RunWithContext runs the command until it completes or the context is cancelled
+func (o *Options) RunWithContext(ctx context.Context) error {
+	err := o.validate(ctx)
 	if err != nil {
 		return err
 	}
 
+	if o.LogArchive != "" && o.LogDir == "" {
+		defer os.RemoveAll(o.logDir)
+	}
+
 	client := o.KubeClient
 	selector := o.Selector
 	ns := o.Namespace
 
+	if o.FromCronJob != "" {
+		job, err := o.createJobFromCronJob(ctx, client, ns, o.FromCronJob)
+		if err != nil {
+			return err
+		}
+		r := o.verifyJob(ctx, client, ns, "job-name="+job.Name, job.Name)
+		o.cleanupJob(ctx, client, ns, job.Name, r.Error)
+		return o.reportResult(ctx, r.Error)
+	}
+
+	if len(o.Files) > 0 {
+		jobList, err := o.createJobsFromFiles(ctx, client, ns)
+		if err != nil {
+			return err
+		}
+		results := o.verifyJobsConcurrently(ctx, client, ns, jobList)
+		for i := range results {
+			o.cleanupJob(ctx, client, ns, results[i].Name, results[i].Error)
+		}
+		err = o.reportJobResults(results)
+		return o.reportResult(ctx, err)
+	}
+
 	if o.Name != "" {
 		selector = "job-name=" + o.Name
-		_, err = o.waitForJobToExist(client, ns, o.Name)
+		_, err = o.waitForJobToExist(ctx, client, ns, o.Name)
 		if err != nil {
 			return fmt.Errorf("failed to wait for job %s: %w", o.Name, err)
 		}
-		err = o.viewActiveJobLog(client, ns, selector, o.Name)
-		return o.handleErrorReporting(err)
+		r := o.verifyJob(ctx, client, ns, selector, o.Name)
+		return o.reportResult(ctx, r.Error)
 	}
 
-	jobs, err := GetSortedJobs(client, ns, selector, o.FieldSelector)
+	jobs, err := GetSortedJobs(client, ns, selector, o.FieldSelector, o.CommitSha)
 	if err != nil {
 		return fmt.Errorf("failed to get jobs: %w", err)
 	}
 
-	err = o.pickJobToLog(client, ns, selector, jobs)
-	return o.handleErrorReporting(err)
+	if o.All {
+		err = o.verifyAllJobs(ctx, client, ns, jobs)
+		return o.reportResult(ctx, err)
+	}
+
+	err = o.pickJobToLog(ctx, client, ns, selector, jobs)
+	return o.reportResult(ctx, err)
+}
+
+// reportResult writes any requested reports of the verified jobs then handles the error. A failure to write a
+// report is returned along with the result of the verification rather than instead of it
+func (o *Options) reportResult(ctx context.Context, err error) error {
+	var reportErrs []error
+	if o.JUnitFile != "" {
+		reportErr := o.writeJUnitReport(o.JUnitFile)
+		if reportErr != nil {
+			reportErrs = append(reportErrs, reportErr)
+		}
+	}
+	if o.LogArchive != "" {
+		reportErr := createTarball(o.logDir, o.LogArchive)
+		if reportErr != nil {
+			reportErrs = append(reportErrs, fmt.Errorf("failed to archive logs: %w", reportErr))
+		} else {
+			logger.Logger().Infof("saved the job logs to %s", info(o.LogArchive))
+		}
+	}
+	if ctx.Err() != nil {
+		// being cancelled is not a result of the job so lets not hide it with --log-fail
+		err = fmt.Errorf("verification cancelled: %w", ctx.Err())
+	} else {
+		err = o.handleErrorReporting(err)
+	}
+	return errors.Join(append([]error{err}, reportErrs...)...)
 }
 
 func (o *Options) handleErrorReporting(err error) error {
@@ -153,17 +333,32 @@ func (o *Options) handleErrorReporting(err error) error {
 	return nil
 }
 
-func (o *Options) viewActiveJobLog(client kubernetes.Interface, ns, selector, jobName string) error {
-	var foundPods []string
+func (o *Options) viewActiveJobLog(ctx context.Context, client kubernetes.Interface, ns, selector, jobName string) error {
+	w, err := newJobWatcher(client, ns, jobName, selector, o.PollPeriod)
+	if err != nil {
+		return fmt.Errorf("failed to watch job %s: %w", jobName, err)
+	}
+	defer w.Stop()
+	stopHeartbeat := o.startHeartbeat(ctx, w)
+	defer stopHeartbeat()
+
+	// lets keep writing the log of a pod to the same writer if we reconnect so artifacts are not split
+	logs := map[string]*podLogWriter{}
+	defer func() {
+		for _, l := range logs {
+			l.Flush()
+		}
+	}()
+
 	logger.Logger().Infof("waiting for a running pod in namespace %s with selector %s", info(ns), info(selector))
 	for {
-		complete, pod, err := o.waitForJobCompleteOrPodRunning(client, ns, selector, jobName)
+		complete, pod, err := o.waitForJobCompleteOrPodRunning(ctx, w, jobName)
 		if err != nil {
 			return err
 		}
 		if complete {
-			if o.VerifyResult {
-				return o.verifyResultInLastPod(client, ns, selector)
+			if o.VerifyResult || o.logAssertions != nil || o.ArtifactsDir != "" {
+				return o.verifyLastPod(ctx, client, ns, selector, jobName, logs)
 			}
 			return nil
 		}
@@ -175,30 +370,74 @@ func (o *Options) viewActiveJobLog(client kubernetes.Interface, ns, selector, jo
 			return fmt.Errorf("timed out after waiting for duration %s", o.Duration.String())
 		}
 
-		// lets verify the container name
-		containerName := o.ContainerName
-		if containerName == "" {
-			containerName = pod.Spec.Containers[0].Name
-		}
-		err = verifyContainerName(pod, containerName)
-		if err != nil {
-			return err
-		}
 		podName := pod.Name
-		if stringhelpers.StringArrayIndex(foundPods, podName) < 0 {
-			foundPods = append(foundPods, podName)
+		if o.MainContainer != "" {
+			err = verifyContainerName(pod, o.MainContainer)
+			if err != nil {
+				return err
+			}
 		}
-		logger.Logger().Infof("\ntailing pod %s\n\n", info(podName))
+		if o.AllContainers {
+			// the containers are tailed with their own writers so lets just record the pod has been tailed
+			if logs[podName] == nil {
+				logger.Logger().Infof("\ntailing all containers of pod %s\n\n", info(podName))
+				logs[podName] = &podLogWriter{}
+			}
 
-		err = podlogs.TailLogs(ns, podName, containerName, o.ErrOut, o.Out)
-		if err != nil {
-			logger.Logger().Warnf("failed to tail log: %s", err.Error())
+			o.tailAllContainers(ctx, w, ns, pod)
+		} else {
+			// lets verify the container name
+			containerName := o.ContainerName
+			if containerName == "" {
+				containerName = pod.Spec.Containers[0].Name
+			}
+			err = verifyContainerName(pod, containerName)
+			if err != nil {
+				return err
+			}
+			l := logs[podName]
+			if l == nil {
+				logger.Logger().Infof("\ntailing pod %s\n\n", info(podName))
+				l = o.newPodLogWriter(jobName, podName)
+				logs[podName] = l
+			}
+			err = o.tailLogs(ctx, ns, podName, containerName, l.artifacts)
+			if err != nil {
+				if ctx.Err() != nil {
+					return err
+				}
+				logger.Logger().Warnf("failed to tail log: %s", err.Error())
+			}
 		}
-		pod, err = client.CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+		pod, err = client.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
 		if err != nil {
 			return fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, ns, err)
 		}
+		if pods.IsPodCompleted(pod) || pod.DeletionTimestamp != nil {
+			// the log of the pod has ended so lets write any partial line
+			logs[podName].Flush()
+		}
+		if o.MainContainer != "" && !pods.IsPodCompleted(pod) {
+			// sidecars can keep the pod running after the main container has terminated
+			terminated, err := o.mainContainerResult(pod)
+			if terminated {
+				if err == nil || !o.canRetryMainContainer(w) {
+					// lets verify the pod before stopping its sidecars as deleting the pod loses its log
+					if err == nil {
+						err = o.verifyPod(ctx, ns, jobName, pod, true)
+					}
+					o.stopSidecars(ctx, client, ns, jobName, pod, false)
+					return err
+				}
+				o.stopSidecars(ctx, client, ns, jobName, pod, true)
+				// once the sidecars have stopped the pod fails so lets wait for the job to create the next attempt
+				logger.Logger().Infof("waiting for job %s to retry as its backoff limit has not been reached", info(jobName))
+				w.IgnorePod(podName)
+				continue
+			}
+		}
 		if pods.IsPodCompleted(pod) {
+			w.IgnorePod(podName)
 			if pods.IsPodSucceeded(pod) {
 				logger.Logger().Infof("pod %s has %s", info(podName), info("Succeeded"))
 			} else {
@@ -206,20 +445,75 @@ func (o *Options) viewActiveJobLog(client kubernetes.Interface, ns, selector, jo
 			}
 		} else if pod.DeletionTimestamp != nil {
 			logger.Logger().Infof("pod %s is %s", info(podName), termcolor.ColorWarning("Terminating"))
+		} else {
+			// the log ended while the pod is still running so lets wait for it to change before tailing again
+			err = w.Wait(ctx, o.PollPeriod)
+			if err != nil {
+				return err
+			}
 		}
 	}
 }
 
 // Validate verifies the settings are correct and we can lazy create any required resources
 func (o *Options) Validate() error {
+	return o.validate(context.Background())
+}
+
+// validate validates the options using the context to look for the namespace of the jobs
+func (o *Options) validate(ctx context.Context) error {
 	if o.Selector == "" {
-		if o.Name == "" {
+		if o.Name == "" && o.FromCronJob == "" && len(o.Files) == 0 && o.CommitSha == "" {
 			return options.MissingOption("selector")
 		}
 	}
+	selections := 0
+	for _, selected := range []bool{o.All, o.Active, o.Latest, o.Index != nil} {
+		if selected {
+			selections++
+		}
+	}
+	if selections > 1 {
+		return fmt.Errorf("only one of --all, --active, --latest and --index can be specified")
+	}
+	if o.Cleanup == "" {
+		o.Cleanup = CleanupNever
+	}
+	if stringhelpers.StringArrayIndex(CleanupValues, o.Cleanup) < 0 {
+		return options.InvalidOption("cleanup", o.Cleanup, CleanupValues)
+	}
+	if o.logAssertions == nil {
+		var err error
+		o.logAssertions, err = newLogAssertions(o.ExpectLogs, o.FailOnLogs)
+		if err != nil {
+			return err
+		}
+	}
+	if o.StopSidecars == "" {
+		o.StopSidecars = StopSidecarsNone
+	}
+	if stringhelpers.StringArrayIndex(StopSidecarsValues, o.StopSidecars) < 0 {
+		return options.InvalidOption("stop-sidecars", o.StopSidecars, StopSidecarsValues)
+	}
+	if o.StopSidecars != StopSidecarsNone && o.MainContainer == "" {
+		return fmt.Errorf("--stop-sidecars can only be used with --main-container as the sidecars are stopped once the main container has terminated")
+	}
+	if o.ContainerName == "" {
+		o.ContainerName = o.MainContainer
+	}
 	if o.FieldSelector == "" && o.Name != "" {
 		o.FieldSelector = "metadata.name=" + o.Name
 	}
+	if o.logDir == "" {
+		o.logDir = o.LogDir
+		if o.logDir == "" && o.LogArchive != "" {
+			var err error
+			o.logDir, err = os.MkdirTemp("", "jx-verify-logs-")
+			if err != nil {
+				return fmt.Errorf("failed to create temporary directory for the logs: %w", err)
+			}
+		}
+	}
 	if o.NoTail {
 		return nil
 	}
@@ -235,10 +529,20 @@ func (o *Options) Validate() error {
 	if err != nil {
 		return fmt.Errorf("failed to create kubernetes client: %w", err)
 	}
+	if o.LogSource == nil {
+		o.LogSource = podlogs.NewKubeLogSource(o.KubeClient)
+	}
+	if o.PortForwarder == nil && o.StopSidecars == StopSidecarsIstio {
+		cfg, err := kubeclient.NewFactory().CreateKubeConfig()
+		if err != nil {
+			return fmt.Errorf("failed to get kubernetes config: %w", err)
+		}
+		o.PortForwarder = NewKubePortForwarder(cfg, o.KubeClient)
+	}
 	if o.Namespace == "" {
-		o.Namespace, err = kubeclient.CurrentNamespace()
+		o.Namespace, err = o.findNamespace(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to detect current namespace. Try supply --namespace: %w", err)
+			return err
 		}
 	}
 	if o.Input == nil {
@@ -248,18 +552,26 @@ func (o *Options) Validate() error {
 	return nil
 }
 
-func (o *Options) waitForJobToExist(client kubernetes.Interface, ns, jobName string) (*batchv1.Job, error) {
+func (o *Options) waitForJobToExist(ctx context.Context, client kubernetes.Interface, ns, jobName string) (*batchv1.Job, error) {
 	logged := false
 
+	w, err := newJobWatcher(client, ns, jobName, "", o.PollPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch job %s: %w", jobName, err)
+	}
+	defer w.Stop()
+	stopHeartbeat := o.startHeartbeat(ctx, w)
+	defer stopHeartbeat()
+
 	for {
-		job, err := client.BatchV1().Jobs(ns).Get(context.TODO(), jobName, metav1.GetOptions{})
-		if err == nil && job != nil {
+		job, err := w.GetJob()
+		if err != nil {
+			return nil, fmt.Errorf("failed to look for job %s in namespace %s: %w", jobName, ns, err)
+		}
+		if job != nil {
 			logger.Logger().Infof("found Job %s in namespace %s", info(jobName), info(ns))
 			return job, nil
 		}
-		if err != nil && !apierrors.IsNotFound(err) {
-			return nil, fmt.Errorf("failed to look for job %s in namespace %s: %w", jobName, ns, err)
-		}
 
 		if !logged {
 			logged = true
@@ -268,16 +580,20 @@ func (o *Options) waitForJobToExist(client kubernetes.Interface, ns, jobName str
 		if time.Now().After(o.timeEnd) {
 			return nil, fmt.Errorf("timed out after waiting for duration %s", o.Duration.String())
 		}
-		time.Sleep(o.PollPeriod)
+		err = w.Wait(ctx, o.PollPeriod)
+		if err != nil {
+			return nil, err
+		}
 	}
 }
 
-func (o *Options) verifyResultInLastPod(client kubernetes.Interface, ns, selector string) error {
+// verifyLastPod verifies the log of the last pod of the completed job against the log assertions and the result protocol
+// saving the artifacts of any pod of the job whose log was not tailed
+func (o *Options) verifyLastPod(ctx context.Context, client kubernetes.Interface, ns, selector, jobName string, logs map[string]*podLogWriter) error {
 	opts := metav1.ListOptions{
 		LabelSelector: selector,
 	}
 	podInterface := client.CoreV1().Pods(ns)
-	ctx := context.TODO()
 	podList, err := podInterface.List(ctx, opts)
 	if err != nil && apierrors.IsNotFound(err) {
 		err = nil
@@ -299,37 +615,106 @@ func (o *Options) verifyResultInLastPod(client kubernetes.Interface, ns, selecto
 		}
 	}
 
+	// earlier attempts may have completed before we could tail them
+	if o.ArtifactsDir != "" {
+		for i := range pods {
+			p := &pods[i]
+			if p.Name != pod.Name && logs[p.Name] == nil {
+				o.saveArtifacts(ctx, ns, jobName, p.Name)
+			}
+		}
+	}
+	return o.verifyPod(ctx, ns, jobName, &pod, logs[pod.Name] != nil)
+}
+
+// verifyPod verifies the log of the pod against the log assertions and the result protocol saving its artifacts
+// from the log if the log was not tailed
+func (o *Options) verifyPod(ctx context.Context, ns, jobName string, pod *corev1.Pod, tailed bool) error {
+	saveArtifacts := o.ArtifactsDir != "" && !tailed
+	if !o.VerifyResult && o.logAssertions == nil && !saveArtifacts {
+		return nil
+	}
+	var err error
+
+	// lets prefer the result in the termination message as the log may be huge or rotated
+	var results *TestResults
+	if o.VerifyResult {
+		message := terminationMessage(pod, o.ContainerName)
+		if message != "" {
+			results, err = ParseTerminationMessage(message)
+			if err != nil {
+				return fmt.Errorf("failed to parse termination message of pod %s: %w", pod.Name, err)
+			}
+			if results.IsEmpty() {
+				results = nil
+			} else {
+				logger.Logger().Infof("pod %s reported its result in its termination message", info(pod.Name))
+			}
+		}
+	}
+	if results != nil && o.logAssertions == nil && !saveArtifacts {
+		return o.verifyResults(pod.Name, results)
+	}
+
 	// lets get the log of the pod
-	result := podInterface.GetLogs(pod.Name, &v1.PodLogOptions{
+	data, err := o.readLog(ctx, ns, pod.Name, &v1.PodLogOptions{
 		Container: o.ContainerName,
-	}).Do(ctx)
-	data, err := result.Raw()
+	})
 	if err != nil {
 		return fmt.Errorf("failed to read logs in namespace %s pod %s: %w", ns, pod.Name, err)
 	}
+	if saveArtifacts {
+		o.writeArtifacts(jobName, pod.Name, data)
+	}
+	if !o.VerifyResult && o.logAssertions == nil {
+		return nil
+	}
 	lines := strings.Split(string(data), "\n")
-	for _, line := range lines {
-		if !strings.HasPrefix(line, PodResultPrefix) {
-			continue
-		}
-		remaining := strings.TrimPrefix(line, PodResultPrefix)
-		remaining = strings.TrimSpace(remaining)
-		logger.Logger().Infof("pod %s has result %s", info(pod.Name), info(remaining))
-		if remaining == PodResultOK {
-			return nil
+	if o.logAssertions != nil {
+		err = o.logAssertions.Verify(pod.Name, lines)
+		if err != nil {
+			return err
 		}
-		return fmt.Errorf("pod %s %s", pod.Name, remaining)
 	}
-	return fmt.Errorf("pod %s did not output expected line: %s", pod.Name, PodResultPrefix)
+	if !o.VerifyResult {
+		return nil
+	}
+	if results == nil {
+		results, err = ParseTestResults(lines)
+		if err != nil {
+			return fmt.Errorf("failed to parse results of pod %s: %w", pod.Name, err)
+		}
+	}
+	return o.verifyResults(pod.Name, results)
 }
 
-func (o *Options) waitForJobCompleteOrPodRunning(client kubernetes.Interface, ns, selector, jobName string) (bool, *corev1.Pod, error) {
-	if o.podStatusMap == nil {
-		o.podStatusMap = map[string]string{}
+// verifyResults logs the results of the pod returning an error if the pod failed
+func (o *Options) verifyResults(podName string, results *TestResults) error {
+	if results.HasMarker {
+		logger.Logger().Infof("pod %s has result %s", info(podName), info(results.Marker))
 	}
+	results.WriteSummary(o.Out)
+	return results.Verify(podName)
+}
 
+// terminationMessage returns the termination message of the given container or the first container if no name is given
+func terminationMessage(pod *corev1.Pod, containerName string) string {
+	if containerName == "" {
+		if len(pod.Spec.Containers) == 0 {
+			return ""
+		}
+		containerName = pod.Spec.Containers[0].Name
+	}
+	status := findContainerStatus(pod, containerName, false)
+	if status == nil || status.State.Terminated == nil {
+		return ""
+	}
+	return strings.TrimSpace(status.State.Terminated.Message)
+}
+
+func (o *Options) waitForJobCompleteOrPodRunning(ctx context.Context, w *jobWatcher, jobName string) (bool, *corev1.Pod, error) {
 	for {
-		complete, job, err := o.checkIfJobComplete(client, ns, jobName)
+		complete, job, err := o.checkIfJobComplete(w)
 		if err != nil {
 			return false, nil, fmt.Errorf("failed to check for Job %s complete: %w", jobName, err)
 		}
@@ -340,32 +725,89 @@ func (o *Options) waitForJobCompleteOrPodRunning(client kubernetes.Interface, ns
 			return true, nil, nil
 		}
 
-		pod, err := pods.GetRunningPodForSelector(client, ns, selector)
+		pod, err := getRunningPod(w, o.AllContainers)
 		if err != nil {
-			return false, pod, fmt.Errorf("failed to query ready pod in namespace %s with selector %s: %w", ns, selector, err)
+			return false, pod, fmt.Errorf("failed to query running pod of job %s: %w", jobName, err)
 		}
 		if pod != nil {
 			status := pods.PodStatus(pod)
-			if o.podStatusMap[pod.Name] != status && !pods.IsPodCompleted(pod) && pod.DeletionTimestamp == nil {
+			if !pods.IsPodCompleted(pod) && pod.DeletionTimestamp == nil && o.updatePodStatus(pod.Name, status) {
 				logger.Logger().Infof("pod %s has status %s", termcolor.ColorInfo(pod.Name), termcolor.ColorInfo(status))
-				o.podStatusMap[pod.Name] = status
-			}
-			if pod.Status.Phase == v1.PodRunning || pods.IsPodReady(pod) {
-				return false, pod, nil
 			}
+			return false, pod, nil
+		}
+
+		err = o.checkForStuckPods(w, jobName)
+		if err != nil {
+			return false, nil, err
 		}
 
 		if time.Now().After(o.timeEnd) {
 			return false, nil, fmt.Errorf("timed out after waiting for duration %s", o.Duration.String())
 		}
-		time.Sleep(o.PollPeriod)
+		err = w.Wait(ctx, o.PollPeriod)
+		if err != nil {
+			return false, nil, err
+		}
+	}
+}
+
+// checkForStuckPods returns an error if a pod of the job can never start or has not been able to start within the grace period
+func (o *Options) checkForStuckPods(w *jobWatcher, jobName string) error {
+	podList, err := w.ListPods()
+	if err != nil {
+		return fmt.Errorf("failed to query pods of job %s: %w", jobName, err)
+	}
+	now := time.Now()
+	for _, pod := range podList {
+		reason := podStuckReason(pod, o.Grace, now)
+		if reason != "" {
+			return fmt.Errorf("pod %s of job %s cannot start as %s", pod.Name, jobName, reason)
+		}
 	}
+	return nil
 }
 
-func (o *Options) checkIfJobComplete(client kubernetes.Interface, ns, name string) (bool, *batchv1.Job, error) {
-	job, err := client.BatchV1().Jobs(ns).Get(context.TODO(), name, metav1.GetOptions{})
-	if job == nil || err != nil {
-		return false, nil, fmt.Errorf("failed to list jobList in namespace %s name %s: %w", ns, name, err)
+// getRunningPod returns the first running pod of the job or nil if there is none. If initializing is true
+// then pending pods with a started init container are also returned
+func getRunningPod(w *jobWatcher, initializing bool) (*corev1.Pod, error) {
+	podList, err := w.ListPods()
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range podList {
+		if pod.Status.Phase == v1.PodRunning {
+			return pod, nil
+		}
+		if initializing && pod.Status.Phase == v1.PodPending && hasStartedInitContainer(pod) {
+			return pod, nil
+		}
+	}
+	return nil, nil
+}
+
+// updatePodStatus records the latest status of the pod returning true if it has changed
+func (o *Options) updatePodStatus(podName, status string) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.podStatusMap == nil {
+		o.podStatusMap = map[string]string{}
+	}
+	if o.podStatusMap[podName] == status {
+		return false
+	}
+	o.podStatusMap[podName] = status
+	return true
+}
+
+func (o *Options) checkIfJobComplete(w *jobWatcher) (bool, *batchv1.Job, error) {
+	job, err := w.GetJob()
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to get job %s: %w", w.jobName, err)
+	}
+	if job == nil {
+		return false, nil, fmt.Errorf("job %s does not exist", w.jobName)
 	}
 	if jobs.IsJobFinished(job) {
 		if jobs.IsJobSucceeded(job) {
@@ -379,24 +821,167 @@ func (o *Options) checkIfJobComplete(client kubernetes.Interface, ns, name strin
 	return false, job, nil
 }
 
-func (o *Options) pickJobToLog(client kubernetes.Interface, ns, selector string, jobs []batchv1.Job) error {
-	var names []string
-	m := map[string]*batchv1.Job{}
-	for i := range jobs {
-		j := &jobs[i]
-		name := toJobName(j, len(jobs)-i)
-		m[name] = j
-		names = append(names, name)
+func (o *Options) pickJobToLog(ctx context.Context, client kubernetes.Interface, ns, selector string, jobs []batchv1.Job) error {
+	jobName, err := o.selectJob(ctx, client, ns, selector, jobs)
+	if err != nil {
+		return err
+	}
+	r := o.verifyJob(ctx, client, ns, "job-name="+jobName, jobName)
+	return r.Error
+}
+
+// jobResult the result of verifying a single job
+type jobResult struct {
+	Name     string
+	Error    error
+	Duration time.Duration
+	Attempts []JobAttempt
+	Retried  bool
+}
+
+func (o *Options) verifyAllJobs(ctx context.Context, client kubernetes.Interface, ns string, jobList []batchv1.Job) error {
+	if len(jobList) == 0 {
+		return fmt.Errorf("no jobs found in namespace %s with selector %s", ns, o.Selector)
+	}
+
+	logger.Logger().Infof("verifying %d jobs in namespace %s with selector %s", len(jobList), info(ns), info(o.Selector))
+
+	results := o.verifyJobsConcurrently(ctx, client, ns, jobList)
+	return o.reportJobResults(results)
+}
+
+// verifyJobsConcurrently verifies each of the jobs at the same time returning their results
+func (o *Options) verifyJobsConcurrently(ctx context.Context, client kubernetes.Interface, ns string, jobList []batchv1.Job) []jobResult {
+	results := make([]jobResult, len(jobList))
+	o.prefixJobs = len(jobList) > 1
+	wg := sync.WaitGroup{}
+	for i := range jobList {
+		name := jobList[i].Name
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = o.verifyJob(ctx, client, ns, "job-name="+name, name)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+// verifyJob waits for the job to complete, tailing its log, and then records the result
+func (o *Options) verifyJob(ctx context.Context, client kubernetes.Interface, ns, selector, jobName string) jobResult {
+	r := jobResult{
+		Name:  jobName,
+		Error: o.viewActiveJobLog(ctx, client, ns, selector, jobName),
+	}
+	if ctx.Err() != nil {
+		r.Error = fmt.Errorf("verification of job %s cancelled: %w", jobName, ctx.Err())
+		if o.DeleteOnCancel {
+			o.deleteCancelledJob(client, ns, jobName)
+		}
+		return r
+	}
+
+	// lets list the pods of the job once for all of the reports
+	podItems, podsErr := listJobPods(ctx, client, ns, jobName)
+	if podsErr != nil {
+		logger.Logger().Warnf("failed to list the pods of job %s: %s", jobName, podsErr.Error())
+	}
+
+	job, err := client.BatchV1().Jobs(ns).Get(ctx, jobName, metav1.GetOptions{})
+	if err != nil {
+		logger.Logger().Warnf("failed to get job %s in namespace %s: %s", jobName, ns, err.Error())
+	} else {
+		r.Duration = jobDuration(job)
+
+		r.Attempts = o.getJobAttempts(podItems)
+		r.Retried = r.Error == nil && len(retriedAttempts(r.Attempts)) > 0
+		o.reportJobAttempts(job, r.Attempts, r.Error == nil)
+
+		o.reportJobTiming(job, podItems)
+
+		if r.Error != nil {
+			err = o.reportJobDiagnosis(ctx, client, ns, job, podItems)
+			if err != nil {
+				logger.Logger().Warnf("failed to diagnose job %s: %s", jobName, err.Error())
+			}
+		}
+	}
+
+	if o.logDir != "" && podsErr == nil {
+		err = o.archiveJobLogs(ctx, ns, jobName, podItems)
+		if err != nil {
+			logger.Logger().Warnf("failed to save the logs of job %s: %s", jobName, err.Error())
+		}
+	}
+
+	if o.JUnitFile != "" {
+		suite := o.createJUnitTestSuite(ctx, ns, &r, podItems)
+		o.lock.Lock()
+		o.junitSuites = append(o.junitSuites, *suite)
+		o.lock.Unlock()
 	}
+	return r
+}
 
-	name, err := o.Input.PickNameWithDefault(names, "select the Job to view:", "", "select which job you wish to log")
+// listJobPods returns the pods of the job sorted by creation
+func listJobPods(ctx context.Context, client kubernetes.Interface, ns, jobName string) ([]corev1.Pod, error) {
+	selector := "job-name=" + jobName
+	podList, err := client.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to pick a job name: %w", err)
+		return nil, fmt.Errorf("failed to list pods in namespace %s with selector %s: %w", ns, selector, err)
 	}
-	if name == "" {
-		return fmt.Errorf("no jobs to view. Try add --active to wait for the next job")
+	podItems := podList.Items
+	sort.Slice(podItems, func(i, j int) bool {
+		return podItems[i].CreationTimestamp.Before(&podItems[j].CreationTimestamp)
+	})
+	return podItems, nil
+}
+
+func (o *Options) reportJobResults(results []jobResult) error {
+	var failed []string
+	t := table.CreateTable(o.Out)
+	t.AddRow("JOB", "RESULT", "DURATION")
+	for i := range results {
+		r := &results[i]
+		result := info("Succeeded")
+		if r.Retried {
+			result = termcolor.ColorWarning("Succeeded on retry")
+		}
+		if r.Error != nil {
+			result = termcolor.ColorError("Failed")
+			failed = append(failed, r.Name)
+			logger.Logger().Infof("job %s failed: %s", info(r.Name), r.Error.Error())
+		}
+		t.AddRow(r.Name, result, r.Duration.Round(time.Second).String())
+	}
+	t.Render()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d jobs failed: %s", len(failed), len(results), strings.Join(failed, ", "))
 	}
-	return o.viewActiveJobLog(client, ns, selector, name)
+	return nil
+}
+
+// jobDuration returns how long the job has been running for or took to complete
+func jobDuration(j *batchv1.Job) time.Duration {
+	start := j.CreationTimestamp.Time
+	if j.Status.StartTime != nil {
+		start = j.Status.StartTime.Time
+	}
+	if start.IsZero() {
+		return 0
+	}
+	if j.Status.CompletionTime != nil {
+		return j.Status.CompletionTime.Sub(start)
+	}
+	for _, c := range j.Status.Conditions {
+		if c.Type == batchv1.JobFailed && c.Status == corev1.ConditionTrue {
+			return c.LastTransitionTime.Sub(start)
+		}
+	}
+	return time.Since(start)
 }
 
 func toJobName(j *batchv1.Job, number int) string {
@@ -431,7 +1016,7 @@ func verifyContainerName(pod *corev1.Pod, name string) error {
 }
 
 // GetSortedJobs gets the jobs with an optional commit sha filter
-func GetSortedJobs(client kubernetes.Interface, ns, selector, fieldSelector string) ([]batchv1.Job, error) {
+func GetSortedJobs(client kubernetes.Interface, ns, selector, fieldSelector, commitSha string) ([]batchv1.Job, error) {
 	jobList, err := client.BatchV1().Jobs(ns).List(context.TODO(), metav1.ListOptions{
 		LabelSelector: selector,
 		FieldSelector: fieldSelector,
@@ -440,7 +1025,12 @@ func GetSortedJobs(client kubernetes.Interface, ns, selector, fieldSelector stri
 		return nil, fmt.Errorf("failed to list jobList in namespace %s selector %s: %w", ns, selector, err)
 	}
 
-	answer := jobList.Items
+	var answer []batchv1.Job
+	for i := range jobList.Items {
+		if MatchesCommitSha(&jobList.Items[i], commitSha) {
+			answer = append(answer, jobList.Items[i])
+		}
+	}
 	sort.Slice(answer, func(i, j int) bool {
 		j1 := answer[i]
 		j2 := answer[j]
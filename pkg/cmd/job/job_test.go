@@ -1,17 +1,806 @@
 package job_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/yamls"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
-func TestVerifyJob(_ *testing.T) {
+func TestVerifyJob(t *testing.T) {
 	ns := "jx"
 	_, o := job.NewCmdVerifyJob()
 	kubeClient := fake.NewSimpleClientset()
 	o.KubeClient = kubeClient
 	o.Namespace = ns
 
+	err := o.Run()
+	require.Error(t, err, "should fail without a selector")
+	assert.Contains(t, err.Error(), "selector")
+}
+
+func TestVerifyAllJobs(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(
+		newFinishedJob(ns, "bdd-1", labels, batchv1.JobComplete),
+		newFinishedJob(ns, "bdd-2", labels, batchv1.JobFailed),
+		newFinishedJob(ns, "bdd-3", labels, batchv1.JobComplete),
+		newFinishedJob(ns, "other", map[string]string{"app": "other"}, batchv1.JobFailed),
+	)
+	o.Namespace = ns
+	o.Selector = "app=jx-bdd"
+	o.All = true
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as a job failed")
+	assert.Contains(t, err.Error(), "1 of 3 jobs failed: bdd-2")
+
+	text := out.String()
+	t.Logf("summary:\n%s\n", text)
+	for _, name := range []string{"bdd-1", "bdd-2", "bdd-3"} {
+		assert.Contains(t, text, name, "summary should contain job %s", name)
+	}
+	assert.NotContains(t, text, "other", "summary should not contain jobs not matching the selector")
+
+	o.LogFail = true
+	err = o.Run()
+	require.NoError(t, err, "should not fail when using --log-fail")
+}
+
+func newFinishedJob(ns, name string, labels map[string]string, conditionType batchv1.JobConditionType) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    labels,
+		},
+		Status: batchv1.JobStatus{
+			Conditions: []batchv1.JobCondition{
+				{
+					Type:   conditionType,
+					Status: corev1.ConditionTrue,
+				},
+			},
+		},
+	}
+}
+
+func TestVerifyJobWaitsForJobToBeCreated(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	_, o := job.NewCmdVerifyJob()
+	kubeClient := newWatchedClientset()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Name = jobName
+	o.Duration = time.Minute
+	o.Out = &bytes.Buffer{}
+
+	go func() {
+		kubeClient.waitForWatches("jobs")
+		_, err := kubeClient.BatchV1().Jobs(ns).Create(context.TODO(), newFinishedJob(ns, jobName, nil, batchv1.JobComplete), metav1.CreateOptions{})
+		assert.NoError(t, err, "failed to create job")
+	}()
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job once it was created")
+}
+
+func TestVerifyJobJUnitReport(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+	junitFile := filepath.Join(t.TempDir(), "reports", "junit.xml")
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(
+		newFinishedJob(ns, "bdd-1", labels, batchv1.JobComplete),
+		newJobPod(ns, "bdd-1-abc", "bdd-1", corev1.PodSucceeded, 0),
+		newFinishedJob(ns, "bdd-2", labels, batchv1.JobFailed),
+		newJobPod(ns, "bdd-2-abc", "bdd-2", corev1.PodFailed, 1),
+		newJobPod(ns, "bdd-2-def", "bdd-2", corev1.PodFailed, 2),
+	)
+	o.Namespace = ns
+	o.Selector = "app=jx-bdd"
+	o.All = true
+	o.JUnitFile = junitFile
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as a job failed")
+
+	data, err := os.ReadFile(junitFile)
+	require.NoError(t, err, "failed to load JUnit report %s", junitFile)
+	text := string(data)
+	t.Logf("JUnit report:\n%s\n", text)
+
+	assert.Contains(t, text, `<testsuites tests="3" failures="2"`)
+	assert.Contains(t, text, `<testsuite name="bdd-1" tests="1" failures="0"`)
+	assert.Contains(t, text, `<testsuite name="bdd-2" tests="2" failures="2"`)
+	assert.Contains(t, text, `<testcase name="bdd-2-def" classname="bdd-2"`)
+	assert.Contains(t, text, "container test terminated with exit code 2")
+	assert.Contains(t, text, "fake logs", "should include a log excerpt")
+}
+
+func TestVerifyJobLogArchive(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	tmpDir := t.TempDir()
+	logDir := filepath.Join(tmpDir, "logs")
+	logArchive := filepath.Join(tmpDir, "logs.tar.gz")
+
+	retryPod := newJobPod(ns, "bdd-abc", jobName, corev1.PodFailed, 1)
+	retryPod.Spec.InitContainers = []corev1.Container{
+		{
+			Name: "setup",
+		},
+	}
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(
+		newFinishedJob(ns, jobName, nil, batchv1.JobComplete),
+		retryPod,
+		newJobPod(ns, "bdd-def", jobName, corev1.PodSucceeded, 0),
+	)
+	o.Namespace = ns
+	o.Name = jobName
+	o.LogDir = logDir
+	o.LogArchive = logArchive
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+
+	expectedFiles := []string{
+		"bdd/manifest.yaml",
+		"bdd/bdd-abc/setup.log",
+		"bdd/bdd-abc/test.log",
+		"bdd/bdd-def/test.log",
+	}
+	for _, f := range expectedFiles {
+		assert.FileExists(t, filepath.Join(logDir, f))
+	}
+
+	manifest := &job.LogManifest{}
+	err = yamls.LoadFile(filepath.Join(logDir, "bdd", job.LogManifestFileName), manifest)
+	require.NoError(t, err, "failed to load manifest")
+	assert.Equal(t, jobName, manifest.Job)
+	require.Len(t, manifest.Pods, 2, "pods in manifest")
+	for _, p := range manifest.Pods {
+		switch p.Name {
+		case "bdd-abc":
+			assert.Equal(t, corev1.PodFailed, p.Phase)
+			require.Len(t, p.Containers, 2, "containers of pod %s", p.Name)
+			assert.True(t, p.Containers[0].Init, "first container should be an init container")
+			require.NotNil(t, p.Containers[1].ExitCode, "exit code of pod %s", p.Name)
+			assert.Equal(t, int32(1), *p.Containers[1].ExitCode)
+		case "bdd-def":
+			assert.Equal(t, corev1.PodSucceeded, p.Phase)
+		default:
+			assert.Fail(t, "unexpected pod "+p.Name)
+		}
+	}
+
+	f, err := os.Open(logArchive)
+	require.NoError(t, err, "failed to open %s", logArchive)
+	defer f.Close()
+	gr, err := gzip.NewReader(f)
+	require.NoError(t, err, "failed to read gzip %s", logArchive)
+	tr := tar.NewReader(gr)
+	var names []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err, "failed to read tarball %s", logArchive)
+		names = append(names, header.Name)
+	}
+	for _, f := range expectedFiles {
+		assert.Contains(t, names, f, "tarball should contain %s", f)
+	}
+}
+
+func TestVerifyJobReportFailureKeepsResult(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	// the archive cannot be written as its directory is a file
+	tmpDir := t.TempDir()
+	notDir := filepath.Join(tmpDir, "file")
+	require.NoError(t, os.WriteFile(notDir, []byte("not a directory"), 0o600))
+
+	newOptions := func() *job.Options {
+		_, o := job.NewCmdVerifyJob()
+		o.KubeClient = fake.NewSimpleClientset(newFinishedJob(ns, jobName, nil, batchv1.JobFailed))
+		o.Namespace = ns
+		o.Name = jobName
+		o.LogArchive = filepath.Join(notDir, "logs.tar.gz")
+		o.JUnitFile = filepath.Join(tmpDir, "junit.xml")
+		o.Out = &bytes.Buffer{}
+		return o
+	}
+
+	err := newOptions().Run()
+	require.Error(t, err, "should have failed")
+	assert.Contains(t, err.Error(), "job bdd failed", "should keep the result of the job")
+	assert.Contains(t, err.Error(), "failed to archive logs", "should report the archive failure")
+	assert.FileExists(t, filepath.Join(tmpDir, "junit.xml"), "should still write the JUnit report")
+
+	o := newOptions()
+	o.LogFail = true
+	text := logger.CaptureOutput(func() {
+		err = o.Run()
+	})
+	require.Error(t, err, "should still fail as the archive could not be written")
+	assert.NotContains(t, err.Error(), "job bdd failed", "--log-fail should log the result of the job")
+	assert.Contains(t, text, job.PodResultPrefix+job.PodResultFailed+"job bdd failed")
+}
+
+func TestVerifyJobDiagnosis(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	diagnosticsDir := t.TempDir()
+
+	oomPod := newJobPod(ns, "bdd-abc", jobName, corev1.PodFailed, 137)
+	oomPod.Status.ContainerStatuses[0].State.Terminated.Reason = "OOMKilled"
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(
+		newFinishedJob(ns, jobName, nil, batchv1.JobFailed),
+		oomPod,
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "bdd-abc.1",
+				Namespace: ns,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "Pod",
+				Name: "bdd-abc",
+			},
+			Type:    corev1.EventTypeWarning,
+			Reason:  "BackOff",
+			Message: "Back-off restarting failed container",
+		},
+		&corev1.Event{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other.1",
+				Namespace: ns,
+			},
+			InvolvedObject: corev1.ObjectReference{
+				Kind: "Pod",
+				Name: "other",
+			},
+			Type:   corev1.EventTypeWarning,
+			Reason: "Unrelated",
+		},
+	)
+	o.Namespace = ns
+	o.Name = jobName
+	o.DiagnosticsDir = diagnosticsDir
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as the job failed")
+
+	text := out.String()
+	t.Logf("diagnosis:\n%s\n", text)
+	assert.Contains(t, text, "OOMKilled")
+	assert.Contains(t, text, "Back-off restarting failed container")
+
+	diagnosis := &job.JobDiagnosis{}
+	err = yamls.LoadFile(filepath.Join(diagnosticsDir, jobName+".yaml"), diagnosis)
+	require.NoError(t, err, "failed to load diagnosis")
+	require.Len(t, diagnosis.Pods, 1, "pods in diagnosis")
+	require.Len(t, diagnosis.Pods[0].Containers, 1, "containers in diagnosis")
+	assert.True(t, diagnosis.Pods[0].Containers[0].OOMKilled, "container should be OOMKilled")
+	require.Len(t, diagnosis.Events, 1, "events in diagnosis")
+	assert.Equal(t, "BackOff", diagnosis.Events[0].Reason)
+}
+
+func TestVerifyJobDiagnosisWithoutEvents(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	diagnosticsDir := t.TempDir()
+
+	oomPod := newJobPod(ns, "bdd-abc", jobName, corev1.PodFailed, 137)
+	oomPod.Status.ContainerStatuses[0].State.Terminated.Reason = "OOMKilled"
+
+	kubeClient := fake.NewSimpleClientset(newFinishedJob(ns, jobName, nil, batchv1.JobFailed), oomPod)
+	kubeClient.PrependReactor("list", "events", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("events"), "", errors.New("not allowed"))
+	})
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Name = jobName
+	o.DiagnosticsDir = diagnosticsDir
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as the job failed")
+	assert.Contains(t, out.String(), "OOMKilled", "should have reported the partial diagnosis")
+
+	diagnosis := &job.JobDiagnosis{}
+	err = yamls.LoadFile(filepath.Join(diagnosticsDir, jobName+".yaml"), diagnosis)
+	require.NoError(t, err, "should have saved the partial diagnosis")
+	require.Len(t, diagnosis.Pods, 1, "pods in diagnosis")
+	assert.Empty(t, diagnosis.Events, "events in diagnosis")
+}
+
+func TestVerifyJobFromCronJob(t *testing.T) {
+	ns := "jx"
+	cronJobName := "my-cronjob"
+
+	kubeClient := fake.NewSimpleClientset(&batchv1.CronJob{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cronJobName,
+			Namespace: ns,
+			UID:       "cronjob-uid",
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule: "@hourly",
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "periodic"},
+				},
+			},
+		},
+	})
+	var created *batchv1.Job
+	kubeClient.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// lets complete the job straight away
+		created = action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		created.Status = newFinishedJob(ns, created.Name, nil, batchv1.JobComplete).Status
+		return false, nil, nil
+	})
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.FromCronJob = cronJobName
+	o.Cleanup = job.CleanupAlways
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+
+	require.NotNil(t, created, "should have created a job")
+	assert.True(t, strings.HasPrefix(created.Name, cronJobName+"-manual-"), "job name %s", created.Name)
+	assert.Equal(t, "periodic", created.Labels["app"])
+	require.Len(t, created.OwnerReferences, 1, "owner references")
+	assert.Equal(t, "CronJob", created.OwnerReferences[0].Kind)
+	assert.Equal(t, cronJobName, created.OwnerReferences[0].Name)
+
+	jobList, err := kubeClient.BatchV1().Jobs(ns).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list jobs")
+	assert.Empty(t, jobList.Items, "the job should have been cleaned up")
+}
+
+func TestVerifyJobFromFile(t *testing.T) {
+	ns := "jx"
+	fileName := filepath.Join(t.TempDir(), "jobs.yaml")
+	err := os.WriteFile(fileName, []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: passes
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: fails
+`), 0o600)
+	require.NoError(t, err, "failed to write %s", fileName)
+
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		// lets complete the jobs straight away
+		created := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		conditionType := batchv1.JobComplete
+		if strings.HasPrefix(created.Name, "fails") {
+			conditionType = batchv1.JobFailed
+		}
+		created.Status = newFinishedJob(ns, created.Name, nil, conditionType).Status
+		return false, nil, nil
+	})
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Files = []string{fileName}
+	o.GenerateSuffix = true
+	o.Cleanup = job.CleanupOnSuccess
+	o.Out = &bytes.Buffer{}
+
+	err = o.Run()
+	require.Error(t, err, "should have failed as one job failed")
+	assert.Contains(t, err.Error(), "1 of 2 jobs failed")
+
+	jobList, err := kubeClient.BatchV1().Jobs(ns).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list jobs")
+	require.Len(t, jobList.Items, 1, "only the failed job should remain")
+	assert.True(t, strings.HasPrefix(jobList.Items[0].Name, "fails-"), "job name %s", jobList.Items[0].Name)
+}
+
+func TestVerifyJobFromFilesDeletesCreatedJobsOnFailure(t *testing.T) {
+	ns := "jx"
+	dir := t.TempDir()
+	var fileNames []string
+	for _, name := range []string{"first", "second", "third"} {
+		fileName := filepath.Join(dir, name+".yaml")
+		err := os.WriteFile(fileName, []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: `+name+`
+`), 0o600)
+		require.NoError(t, err, "failed to write %s", fileName)
+		fileNames = append(fileNames, fileName)
+	}
+
+	kubeClient := fake.NewSimpleClientset()
+	kubeClient.PrependReactor("create", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		created := action.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		if created.Name == "third" {
+			return true, nil, errors.New("quota exceeded")
+		}
+		return false, nil, nil
+	})
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Files = fileNames
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.Error(t, err, "should have failed to create the third job")
+	assert.Contains(t, err.Error(), "failed to create Job third")
+
+	jobList, err := kubeClient.BatchV1().Jobs(ns).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list jobs")
+	assert.Empty(t, jobList.Items, "should have deleted the jobs created before the failure")
+}
+
+func TestVerifyJobLogAssertions(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	// the fake clientset always returns "fake logs" as the log of a pod
+	testCases := []struct {
+		name       string
+		expectLogs []string
+		failOnLogs []string
+		errText    string
+	}{
+		{
+			name:       "expected",
+			expectLogs: []string{"^fake"},
+		},
+		{
+			name:       "missing",
+			expectLogs: []string{"fake", "tests passed"},
+			errText:    "has no line matching --expect-log tests passed",
+		},
+		{
+			name:       "forbidden",
+			failOnLogs: []string{"SKIPPED", "f.ke"},
+			errText:    "log line 1 matches --fail-on-log f.ke: fake logs",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = fake.NewSimpleClientset(
+				newFinishedJob(ns, jobName, nil, batchv1.JobComplete),
+				newJobPod(ns, jobName+"-abc", jobName, corev1.PodSucceeded, 0),
+			)
+			o.Namespace = ns
+			o.Name = jobName
+			o.ExpectLogs = tc.expectLogs
+			o.FailOnLogs = tc.failOnLogs
+			o.Out = &bytes.Buffer{}
+
+			err := o.Run()
+			if tc.errText == "" {
+				require.NoError(t, err, "should have verified the job")
+				return
+			}
+			require.Error(t, err, "should have failed")
+			assert.Contains(t, err.Error(), tc.errText)
+		})
+	}
+}
+
+func TestVerifyJobResultInTerminationMessage(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	pod := newJobPod(ns, jobName+"-abc", jobName, corev1.PodSucceeded, 0)
+	pod.Status.ContainerStatuses[0].State.Terminated.Message = "FAILED: 2 tests failed"
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(newFinishedJob(ns, jobName, nil, batchv1.JobComplete), pod)
+	o.Namespace = ns
+	o.Name = jobName
+	o.VerifyResult = true
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.Error(t, err, "should have failed using the termination message")
+	assert.Equal(t, "pod bdd-abc FAILED: 2 tests failed", err.Error())
+}
+
+func TestVerifyJobFailsFastOnStuckPods(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	testCases := []struct {
+		name    string
+		grace   time.Duration
+		status  corev1.PodStatus
+		errText string
+	}{
+		{
+			name:  "invalid-image",
+			grace: time.Hour,
+			status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "test",
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: "InvalidImageName", Message: "bad image"},
+						},
+					},
+				},
+			},
+			errText: "container test is waiting with reason InvalidImageName: bad image",
+		},
+		{
+			name: "image-pull",
+			status: corev1.PodStatus{
+				InitContainerStatuses: []corev1.ContainerStatus{
+					{
+						Name: "setup",
+						State: corev1.ContainerState{
+							Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"},
+						},
+					},
+				},
+			},
+			errText: "init container setup is waiting with reason ErrImagePull",
+		},
+		{
+			name:  "unschedulable",
+			grace: 30 * time.Second,
+			status: corev1.PodStatus{
+				Conditions: []corev1.PodCondition{
+					{
+						Type:               corev1.PodScheduled,
+						Status:             corev1.ConditionFalse,
+						Reason:             corev1.PodReasonUnschedulable,
+						Message:            "0/3 nodes are available",
+						LastTransitionTime: started,
+					},
+				},
+			},
+			errText: "pod is Unschedulable: 0/3 nodes are available",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pod := newJobPod(ns, jobName+"-abc", jobName, corev1.PodPending, 0)
+			pod.Status = tc.status
+			pod.Status.Phase = corev1.PodPending
+			pod.Status.StartTime = &started
+
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = fake.NewSimpleClientset(
+				&batchv1.Job{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      jobName,
+						Namespace: ns,
+					},
+				},
+				pod,
+			)
+			o.Namespace = ns
+			o.Name = jobName
+			o.Grace = tc.grace
+			o.Duration = 10 * time.Second
+			o.Out = &bytes.Buffer{}
+
+			err := o.Run()
+			require.Error(t, err, "should have failed")
+			assert.Contains(t, err.Error(), tc.errText)
+		})
+	}
+}
+
+func TestVerifyJobDeleteOnCancel(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	kubeClient := fake.NewSimpleClientset(&batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jobName,
+			Namespace: ns,
+		},
+	}, newJobPod(ns, jobName+"-abc", jobName, corev1.PodRunning, 0))
+	kubeClient.PrependReactor("delete", "jobs", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		// lets remove the pods a little later like the garbage collector
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			assert.NoError(t, kubeClient.CoreV1().Pods(ns).Delete(context.TODO(), jobName+"-abc", metav1.DeleteOptions{}), "failed to delete pod")
+		}()
+		return false, nil, nil
+	})
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Name = jobName
+	o.PollPeriod = 10 * time.Millisecond
+	o.DeleteOnCancel = true
+	o.LogFail = true
+	o.Out = &bytes.Buffer{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+
+	err := o.RunWithContext(ctx)
+	require.Error(t, err, "should have been cancelled")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	jobList, err := kubeClient.BatchV1().Jobs(ns).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list jobs")
+	assert.Empty(t, jobList.Items, "the cancelled job should have been deleted")
+
+	podList, err := kubeClient.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{})
+	require.NoError(t, err, "failed to list pods")
+	assert.Empty(t, podList.Items, "should have waited for the pods of the cancelled job to be removed")
+}
+
+func newJobPod(ns, name, jobName string, phase corev1.PodPhase, exitCode int32) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				"job-name": jobName,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "test",
+				},
+			},
+		},
+		Status: corev1.PodStatus{
+			Phase: phase,
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name: "test",
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: exitCode,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// watchedClientset is a fake clientset which does not lose the events which happen between an informer listing and
+// watching. The fake clientset only sends the events which happen after a watch starts so when an informer lists a
+// resource a watch is started straight away and handed to the informer when it starts watching. It also lets tests
+// wait for the informers to watch a resource before changing it
+type watchedClientset struct {
+	*fake.Clientset
+	lock    sync.Mutex
+	cond    *sync.Cond
+	watches map[string]int
+	pending map[string][]watch.Interface
+}
+
+func newWatchedClientset(objects ...runtime.Object) *watchedClientset {
+	c := &watchedClientset{
+		Clientset: fake.NewSimpleClientset(objects...),
+		watches:   map[string]int{},
+		pending:   map[string][]watch.Interface{},
+	}
+	c.cond = sync.NewCond(&c.lock)
+	c.PrependReactor("list", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		la, ok := action.(k8stesting.ListActionImpl)
+		if !ok || la.ListOptions.ResourceVersion != "0" {
+			// only informers list from resource version 0 and then watch
+			return false, nil, nil
+		}
+		w, err := c.Tracker().Watch(action.GetResource(), action.GetNamespace(), la.ListOptions)
+		if err != nil {
+			return true, nil, err
+		}
+		key := watchKey(action, la.ListOptions)
+		c.lock.Lock()
+		c.pending[key] = append(c.pending[key], w)
+		c.lock.Unlock()
+		return false, nil, nil
+	})
+	c.PrependWatchReactor("*", func(action k8stesting.Action) (bool, watch.Interface, error) {
+		var opts metav1.ListOptions
+		if wa, ok := action.(k8stesting.WatchActionImpl); ok {
+			opts = wa.ListOptions
+		}
+		key := watchKey(action, opts)
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		var w watch.Interface
+		if pending := c.pending[key]; len(pending) > 0 {
+			w = pending[0]
+			c.pending[key] = pending[1:]
+		} else {
+			var err error
+			w, err = c.Tracker().Watch(action.GetResource(), action.GetNamespace(), opts)
+			if err != nil {
+				return false, nil, err
+			}
+		}
+		c.watches[action.GetResource().Resource]++
+		c.cond.Broadcast()
+		return true, w, nil
+	})
+	return c
+}
+
+// watchKey returns the key of the list or watch of an informer
+func watchKey(action k8stesting.Action, opts metav1.ListOptions) string {
+	return action.GetResource().Resource + "/" + action.GetNamespace() + "?" + opts.LabelSelector + "&" + opts.FieldSelector
+}
+
+// waitForWatches waits until each of the resources is being watched
+func (c *watchedClientset) waitForWatches(resources ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, r := range resources {
+		for c.watches[r] == 0 {
+			c.cond.Wait()
+		}
+	}
 }
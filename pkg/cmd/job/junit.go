@@ -0,0 +1,202 @@
+package job
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/pods"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// junitLogExcerptLines the number of lines of the pod log to include in a JUnit failure
+const junitLogExcerptLines = 50
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+	duration  time.Duration
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message  string `xml:"message,attr"`
+	Type     string `xml:"type,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// createJUnitTestSuite creates a test suite for the verified job with a test case for each of its pods sorted by creation
+func (o *Options) createJUnitTestSuite(ctx context.Context, ns string, r *jobResult, podItems []corev1.Pod) *junitTestSuite {
+	suite := &junitTestSuite{
+		Name:     r.Name,
+		Time:     junitTime(r.Duration),
+		duration: r.Duration,
+	}
+	for i := range podItems {
+		pod := &podItems[i]
+		tc := junitTestCase{
+			Name:      pod.Name,
+			ClassName: r.Name,
+			Time:      junitTime(podDuration(pod)),
+		}
+
+		message := ""
+		if pod.Status.Phase == corev1.PodFailed {
+			message = podFailureMessage(pod)
+		} else if r.Error != nil && i == len(podItems)-1 {
+			message = r.Error.Error()
+		}
+		if message != "" {
+			tc.Failure = &junitFailure{
+				Message:  message,
+				Type:     "Failure",
+				Contents: o.getLogExcerpt(ctx, ns, pod),
+			}
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+
+	if len(suite.TestCases) == 0 && r.Error != nil {
+		suite.TestCases = append(suite.TestCases, junitTestCase{
+			Name:      r.Name,
+			ClassName: r.Name,
+			Time:      junitTime(r.Duration),
+			Failure: &junitFailure{
+				Message: r.Error.Error(),
+				Type:    "Failure",
+			},
+		})
+	}
+
+	suite.Tests = len(suite.TestCases)
+	for i := range suite.TestCases {
+		if suite.TestCases[i].Failure != nil {
+			suite.Failures++
+		}
+	}
+	return suite
+}
+
+// writeJUnitReport writes the JUnit XML report of all the verified jobs to the given file
+func (o *Options) writeJUnitReport(fileName string) error {
+	o.lock.Lock()
+	suites := append([]junitTestSuite{}, o.junitSuites...)
+	o.lock.Unlock()
+
+	sort.Slice(suites, func(i, j int) bool {
+		return suites[i].Name < suites[j].Name
+	})
+
+	report := &junitTestSuites{
+		Suites: suites,
+	}
+	var total time.Duration
+	for i := range suites {
+		report.Tests += suites[i].Tests
+		report.Failures += suites[i].Failures
+		total += suites[i].duration
+	}
+	report.Time = junitTime(total)
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+	data = append([]byte(xml.Header), data...)
+
+	dir := filepath.Dir(fileName)
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+	err = os.WriteFile(fileName, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to save JUnit report %s: %w", fileName, err)
+	}
+	logger.Logger().Infof("saved JUnit report %s", info(fileName))
+	return nil
+}
+
+// getLogExcerpt returns the last lines of the log of the pod or an empty string if they cannot be found
+func (o *Options) getLogExcerpt(ctx context.Context, ns string, pod *corev1.Pod) string {
+	tailLines := int64(junitLogExcerptLines)
+	data, err := o.readLog(ctx, ns, pod.Name, &corev1.PodLogOptions{
+		Container: o.ContainerName,
+		TailLines: &tailLines,
+	})
+	if err != nil {
+		logger.Logger().Debugf("failed to read logs in namespace %s pod %s: %s", ns, pod.Name, err.Error())
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// podFailureMessage returns a message describing why the pod failed
+func podFailureMessage(pod *corev1.Pod) string {
+	for i := range pod.Status.ContainerStatuses {
+		cs := &pod.Status.ContainerStatuses[i]
+		terminated := cs.State.Terminated
+		if terminated != nil && terminated.ExitCode != 0 {
+			message := fmt.Sprintf("pod %s container %s terminated with exit code %d", pod.Name, cs.Name, terminated.ExitCode)
+			if terminated.Reason != "" {
+				message += " reason " + terminated.Reason
+			}
+			return message
+		}
+	}
+	if pod.Status.Reason != "" {
+		return fmt.Sprintf("pod %s failed with reason %s: %s", pod.Name, pod.Status.Reason, pod.Status.Message)
+	}
+	return fmt.Sprintf("pod %s has %s", pod.Name, pod.Status.Phase)
+}
+
+// podDuration returns how long the pod has been running for or took to complete
+func podDuration(pod *corev1.Pod) time.Duration {
+	start := pod.CreationTimestamp.Time
+	if pod.Status.StartTime != nil {
+		start = pod.Status.StartTime.Time
+	}
+	if start.IsZero() {
+		return 0
+	}
+	if !pods.IsPodCompleted(pod) {
+		return time.Since(start)
+	}
+	end := start
+	for i := range pod.Status.ContainerStatuses {
+		terminated := pod.Status.ContainerStatuses[i].State.Terminated
+		if terminated != nil && terminated.FinishedAt.After(end) {
+			end = terminated.FinishedAt.Time
+		}
+	}
+	return end.Sub(start)
+}
+
+func junitTime(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,59 @@
+package job
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// logAssertions the regular expressions the log of a job pod must, or must not, contain
+type logAssertions struct {
+	expect []*regexp.Regexp
+	failOn []*regexp.Regexp
+}
+
+// newLogAssertions compiles the expected and forbidden log patterns returning nil if there are none
+func newLogAssertions(expectLogs, failOnLogs []string) (*logAssertions, error) {
+	if len(expectLogs) == 0 && len(failOnLogs) == 0 {
+		return nil, nil
+	}
+	answer := &logAssertions{}
+	for _, text := range expectLogs {
+		r, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --expect-log regex %s: %w", text, err)
+		}
+		answer.expect = append(answer.expect, r)
+	}
+	for _, text := range failOnLogs {
+		r, err := regexp.Compile(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --fail-on-log regex %s: %w", text, err)
+		}
+		answer.failOn = append(answer.failOn, r)
+	}
+	return answer, nil
+}
+
+// Verify returns an error for the first line matching a forbidden pattern or the first expected pattern
+// which does not match any line
+func (a *logAssertions) Verify(podName string, lines []string) error {
+	found := make([]bool, len(a.expect))
+	for i, line := range lines {
+		for _, r := range a.failOn {
+			if r.MatchString(line) {
+				return fmt.Errorf("pod %s log line %d matches --fail-on-log %s: %s", podName, i+1, r.String(), line)
+			}
+		}
+		for j, r := range a.expect {
+			if !found[j] && r.MatchString(line) {
+				found[j] = true
+			}
+		}
+	}
+	for j, r := range a.expect {
+		if !found[j] {
+			return fmt.Errorf("pod %s log has no line matching --expect-log %s", podName, r.String())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package job
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// maxLogReconnects the number of times we try to resume an interrupted log stream without receiving any new lines
+	maxLogReconnects = 5
+
+	// logReconnectDelay the delay added for each consecutive attempt to resume an interrupted log stream
+	logReconnectDelay = 500 * time.Millisecond
+)
+
+// logPosition the timestamp of the last line written of a container log and how many lines were written with
+// that timestamp so that a log stream can be resumed without duplicating lines
+type logPosition struct {
+	last  time.Time
+	count int
+	seen  int
+}
+
+// accept parses the timestamp of the line returning the line without the timestamp and true if it has not
+// been written before. Lines without a timestamp are always accepted
+func (p *logPosition) accept(line string) (string, bool) {
+	text := ""
+	ts := line
+	i := strings.IndexByte(line, ' ')
+	if i >= 0 {
+		ts = line[:i]
+		text = line[i+1:]
+	}
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(ts))
+	if err != nil {
+		return line, true
+	}
+	switch {
+	case t.Before(p.last):
+		return "", false
+	case t.Equal(p.last):
+		p.seen++
+		if p.seen <= p.count {
+			return "", false
+		}
+		p.count++
+	default:
+		p.last = t
+		p.count = 1
+		p.seen = 1
+	}
+	return text, true
+}
+
+// getLogPosition returns the position of the log of the container so that we resume from where we got to
+func (o *Options) getLogPosition(ns, podName, containerName string) *logPosition {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.logPositions == nil {
+		o.logPositions = map[string]*logPosition{}
+	}
+	key := ns + "/" + podName + "/" + containerName
+	pos := o.logPositions[key]
+	if pos == nil {
+		pos = &logPosition{}
+		o.logPositions[key] = pos
+	}
+	return pos
+}
+
+// tailLogs follows the log of the container writing each line to the output until the container stops or the
+// context is cancelled. If the stream is interrupted it is resumed from the last line written
+func (o *Options) tailLogs(ctx context.Context, ns, podName, containerName string, out io.Writer) error {
+	pos := o.getLogPosition(ns, podName, containerName)
+	attempt := 0
+	for {
+		last := pos.last
+		interrupted, err := o.streamLog(ctx, ns, podName, containerName, pos, out)
+		if err == nil || ctx.Err() != nil || !interrupted {
+			return err
+		}
+		if !pos.last.Equal(last) {
+			attempt = 0
+		}
+		if attempt >= maxLogReconnects {
+			return err
+		}
+		logger.Logger().Debugf("resuming the log of pod %s container %s: %s", podName, containerName, err.Error())
+
+		delay := time.Duration(attempt) * logReconnectDelay
+		attempt++
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// streamLog writes the lines of the log after the position returning true if the stream was interrupted
+// before the container stopped so that it can be resumed
+func (o *Options) streamLog(ctx context.Context, ns, podName, containerName string, pos *logPosition, out io.Writer) (bool, error) {
+	opts := &corev1.PodLogOptions{
+		Container:  containerName,
+		Follow:     true,
+		Timestamps: true,
+	}
+	if !pos.last.IsZero() {
+		sinceTime := metav1.NewTime(pos.last)
+		opts.SinceTime = &sinceTime
+	}
+	reader, err := o.LogSource.OpenLog(ctx, ns, podName, opts)
+	if err != nil {
+		return false, fmt.Errorf("failed to open log of pod %s container %s: %w", podName, containerName, err)
+	}
+	defer reader.Close()
+
+	// lets close the stream if we are cancelled so that any blocked read returns
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			reader.Close()
+		case <-done:
+		}
+	}()
+
+	pos.seen = 0
+	br := bufio.NewReader(reader)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			text, ok := pos.accept(strings.TrimSuffix(line, "\n"))
+			if ok {
+				_, werr := io.WriteString(out, text+"\n")
+				if werr != nil {
+					return false, fmt.Errorf("failed to write log of pod %s container %s: %w", podName, containerName, werr)
+				}
+				o.markOutput()
+			}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
+			}
+			if errors.Is(err, io.EOF) {
+				return false, nil
+			}
+			return true, fmt.Errorf("failed to read log of pod %s container %s: %w", podName, containerName, err)
+		}
+	}
+}
+
+// readLog reads the whole of the log of the pod using the given options
+func (o *Options) readLog(ctx context.Context, ns, podName string, opts *corev1.PodLogOptions) ([]byte, error) {
+	reader, err := o.LogSource.OpenLog(ctx, ns, podName, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,339 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestVerifyJobTailsPodLog(t *testing.T) {
+	testCases := []struct {
+		name    string
+		log     string
+		errText string
+	}{
+		{
+			name: "ok",
+			log:  "running tests\nPOD RESULT: OK\n",
+		},
+		{
+			name:    "failed",
+			log:     "running tests\nPOD RESULT: FAILED: boom",
+			errText: "pod bdd-abc FAILED: boom",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := "jx"
+			jobName := "bdd"
+			podName := "bdd-abc"
+
+			kubeClient := newWatchedClientset(newActiveJob(ns, jobName), newRunningJobPod(ns, podName, jobName))
+
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = kubeClient
+			o.LogSource = &testhelpers.FakeLogSource{
+				Logs: map[string]string{podName: tc.log},
+				OnFollow: func(podName, _ string) {
+					completeJob(t, kubeClient, ns, jobName, podName)
+				},
+			}
+			o.Namespace = ns
+			o.Name = jobName
+			o.VerifyResult = true
+			o.Duration = 10 * time.Second
+			out := &bytes.Buffer{}
+			o.Out = out
+
+			err := o.Run()
+			if tc.errText == "" {
+				require.NoError(t, err, "should have verified the job")
+			} else {
+				require.Error(t, err, "should have failed")
+				assert.Equal(t, tc.errText, err.Error())
+			}
+			assert.Contains(t, out.String(), "running tests\n", "should have tailed the log")
+		})
+	}
+}
+
+func TestVerifyJobWaitsForRunningPod(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName))
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{podName: "hello\n"},
+		OnFollow: func(podName, _ string) {
+			completeJob(t, kubeClient, ns, jobName, podName)
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	go func() {
+		kubeClient.waitForWatches("jobs", "pods")
+		_, err := kubeClient.CoreV1().Pods(ns).Create(context.TODO(), newRunningJobPod(ns, podName, jobName), metav1.CreateOptions{})
+		assert.NoError(t, err, "failed to create pod")
+	}()
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job once the pod was running")
+	assert.Equal(t, "hello\n", out.String())
+}
+
+func TestVerifyJobWaitsWhenLogEndsBeforePodCompletes(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName), newRunningJobPod(ns, podName, jobName))
+
+	var follows int32
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{podName: "2026-10-17T10:00:00Z hello\n"},
+		OnFollow: func(podName, _ string) {
+			// lets keep the pod running for a while after its log has ended
+			if atomic.AddInt32(&follows, 1) == 1 {
+				go func() {
+					time.Sleep(500 * time.Millisecond)
+					completeJob(t, kubeClient, ns, jobName, podName)
+				}()
+			}
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.PollPeriod = 100 * time.Millisecond
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+	assert.Equal(t, "hello\n", out.String())
+	assert.LessOrEqual(t, atomic.LoadInt32(&follows), int32(10), "should have waited between tailing the log of the running pod")
+}
+
+func TestVerifyJobTailsAllContainers(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	pod := newRunningJobPod(ns, podName, jobName)
+	pod.Spec.InitContainers = []corev1.Container{{Name: "setup"}}
+	pod.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name: "setup",
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{},
+			},
+		},
+	}
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName), pod)
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			podName + "/setup": "setting up\n",
+			podName + "/test":  "testing\n",
+		},
+		OnFollow: func(podName, containerName string) {
+			if containerName == "test" {
+				completeJob(t, kubeClient, ns, jobName, podName)
+			}
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.AllContainers = true
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+
+	text := out.String()
+	assert.Contains(t, text, "[bdd-abc/setup]")
+	assert.Contains(t, text, "setting up\n")
+	assert.Contains(t, text, "[bdd-abc/test]")
+	assert.Contains(t, text, "testing\n")
+	assert.Less(t, strings.Index(text, "setting up"), strings.Index(text, "testing"), "init container should be tailed first")
+}
+
+func TestVerifyAllJobsPrefixesLogs(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+
+	var objects []runtime.Object
+	for _, jobName := range []string{"bdd-1", "bdd-2"} {
+		j := newActiveJob(ns, jobName)
+		j.Labels = labels
+		objects = append(objects, j, newRunningJobPod(ns, jobName+"-abc", jobName))
+	}
+	kubeClient := newWatchedClientset(objects...)
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			"bdd-1-abc": "testing 1\nPOD RESULT: OK\n",
+			"bdd-2-abc": "testing 2\nPOD RESULT: OK\n",
+		},
+		OnFollow: func(podName, _ string) {
+			completeJob(t, kubeClient, ns, strings.TrimSuffix(podName, "-abc"), podName)
+		},
+	}
+	o.Namespace = ns
+	o.Selector = "app=jx-bdd"
+	o.All = true
+	o.VerifyResult = true
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified both jobs")
+
+	text := out.String()
+	t.Logf("output:\n%s\n", text)
+	for i, jobName := range []string{"bdd-1", "bdd-2"} {
+		prefix := "[" + jobName + "/" + jobName + "-abc] "
+		assert.Contains(t, text, fmt.Sprintf("%stesting %d\n", prefix, i+1), "should prefix the log of job %s", jobName)
+		assert.Contains(t, text, prefix+"POD RESULT: OK\n", "should prefix every line of job %s", jobName)
+	}
+}
+
+// resumingLogSource returns a timestamped log which is interrupted the first time it is opened. When resumed
+// it returns the lines since the start of the second of the SinceTime like the API server does
+type resumingLogSource struct {
+	lines    []string
+	opens    []*corev1.PodLogOptions
+	onResume func(podName string)
+}
+
+func (s *resumingLogSource) OpenLog(_ context.Context, _, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	s.opens = append(s.opens, opts.DeepCopy())
+	if len(s.opens) == 1 {
+		text := strings.Join(s.lines[:2], "\n") + "\n"
+		return io.NopCloser(io.MultiReader(strings.NewReader(text), iotest.ErrReader(errors.New("connection reset by peer")))), nil
+	}
+
+	s.onResume(podName)
+	buf := strings.Builder{}
+	for _, line := range s.lines {
+		t, err := time.Parse(time.RFC3339Nano, strings.Fields(line)[0])
+		if err != nil {
+			return nil, err
+		}
+		if opts.SinceTime == nil || !t.Before(opts.SinceTime.Truncate(time.Second)) {
+			buf.WriteString(line + "\n")
+		}
+	}
+	return io.NopCloser(strings.NewReader(buf.String())), nil
+}
+
+func TestVerifyJobResumesInterruptedLog(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName), newRunningJobPod(ns, podName, jobName))
+	source := &resumingLogSource{
+		lines: []string{
+			"2024-01-01T10:00:00.100000000Z line 1",
+			"2024-01-01T10:00:00.200000000Z line 2",
+			"2024-01-01T10:00:00.200000000Z line 3",
+			"2024-01-01T10:00:01.000000000Z line 4",
+		},
+		onResume: func(podName string) {
+			completeJob(t, kubeClient, ns, jobName, podName)
+		},
+	}
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = source
+	o.Namespace = ns
+	o.Name = jobName
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job")
+	assert.Equal(t, "line 1\nline 2\nline 3\nline 4\n", out.String(), "should have printed each line once")
+
+	require.Len(t, source.opens, 2, "should have resumed the log once")
+	assert.True(t, source.opens[0].Timestamps, "should request timestamps")
+	assert.Nil(t, source.opens[0].SinceTime, "should tail from the start")
+	require.NotNil(t, source.opens[1].SinceTime, "should resume from the last line")
+	assert.Equal(t, "2024-01-01T10:00:00.2Z", source.opens[1].SinceTime.UTC().Format(time.RFC3339Nano))
+}
+
+func newActiveJob(ns, name string) *batchv1.Job {
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+	}
+}
+
+func newRunningJobPod(ns, name, jobName string) *corev1.Pod {
+	pod := newJobPod(ns, name, jobName, corev1.PodRunning, 0)
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{
+		Running: &corev1.ContainerStateRunning{},
+	}
+	return pod
+}
+
+// completeJob marks the pod and the job as succeeded once they are being watched
+func completeJob(t *testing.T, kubeClient *watchedClientset, ns, jobName, podName string) {
+	kubeClient.waitForWatches("jobs", "pods")
+
+	ctx := context.TODO()
+	pod, err := kubeClient.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get pod %s", podName)
+	pod.Status.Phase = corev1.PodSucceeded
+	for i := range pod.Status.ContainerStatuses {
+		pod.Status.ContainerStatuses[i].State = corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{},
+		}
+	}
+	_, err = kubeClient.CoreV1().Pods(ns).UpdateStatus(ctx, pod, metav1.UpdateOptions{})
+	require.NoError(t, err, "failed to update pod %s", podName)
+
+	j, err := kubeClient.BatchV1().Jobs(ns).Get(ctx, jobName, metav1.GetOptions{})
+	require.NoError(t, err, "failed to get job %s", jobName)
+	j.Status = newFinishedJob(ns, jobName, nil, batchv1.JobComplete).Status
+	_, err = kubeClient.BatchV1().Jobs(ns).UpdateStatus(ctx, j, metav1.UpdateOptions{})
+	require.NoError(t, err, "failed to update job %s", jobName)
+}
@@ -0,0 +1,138 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultSearchNamespaces the namespaces to look for the jobs in, in order, if no namespace is specified
+var DefaultSearchNamespaces = []string{"jx-git-operator", "jx"}
+
+// findNamespace returns the first of the search namespaces which contains the jobs, or the CronJob, to verify
+// otherwise the current namespace. The Job for a commit may not have been created yet so if no search namespace
+// contains it we use the first search namespace which contains jobs matching the selector
+func (o *Options) findNamespace(ctx context.Context) (string, error) {
+	if len(o.Files) == 0 {
+		description := o.searchDescription()
+		ns, err := o.searchNamespaces(ctx, description, o.containsJobs)
+		if err != nil {
+			return "", err
+		}
+		if ns != "" {
+			logger.Logger().Infof("found %s in namespace %s", description, info(ns))
+			return ns, nil
+		}
+		if o.CommitSha != "" && o.Name == "" && o.FromCronJob == "" {
+			selectorDescription := "jobs with selector " + info(o.Selector)
+			ns, err = o.searchNamespaces(ctx, selectorDescription, o.containsSelectedJobs)
+			if err != nil {
+				return "", err
+			}
+			if ns != "" {
+				logger.Logger().Infof("could not find %s yet so waiting for it in namespace %s which has %s", description, info(ns), selectorDescription)
+				return ns, nil
+			}
+		}
+		if len(o.SearchNamespaces) > 0 {
+			logger.Logger().Infof("could not find %s in namespaces %s so using the current namespace", description, strings.Join(o.SearchNamespaces, ", "))
+		}
+	}
+
+	ns, err := kubeclient.CurrentNamespace()
+	if err != nil {
+		return "", fmt.Errorf("failed to detect current namespace. Try supply --namespace: %w", err)
+	}
+	return ns, nil
+}
+
+// searchNamespaces returns the first of the search namespaces which contains what we are looking for or an empty
+// string if none of them do
+func (o *Options) searchNamespaces(ctx context.Context, description string, contains func(ctx context.Context, ns string) (bool, error)) (string, error) {
+	for _, ns := range o.SearchNamespaces {
+		found, err := contains(ctx, ns)
+		if err != nil {
+			if apierrors.IsForbidden(err) {
+				logger.Logger().Debugf("ignoring namespace %s as we cannot access it: %s", ns, err.Error())
+				continue
+			}
+			return "", fmt.Errorf("failed to look for %s in namespace %s: %w", description, ns, err)
+		}
+		if found {
+			return ns, nil
+		}
+	}
+	return "", nil
+}
+
+// containsJobs returns true if the namespace contains the jobs, or the CronJob, to verify
+func (o *Options) containsJobs(ctx context.Context, ns string) (bool, error) {
+	client := o.KubeClient
+	var err error
+	switch {
+	case o.FromCronJob != "":
+		_, err = client.BatchV1().CronJobs(ns).Get(ctx, o.FromCronJob, metav1.GetOptions{})
+	case o.Name != "":
+		_, err = client.BatchV1().Jobs(ns).Get(ctx, o.Name, metav1.GetOptions{})
+	default:
+		selector := commitShaSelector(o.Selector, o.CommitSha)
+		found, err := o.listContainsJobs(ctx, ns, selector, o.CommitSha)
+		if err != nil || found || selector == o.Selector {
+			return found, err
+		}
+		// the commit sha may only be in an annotation
+		return o.listContainsJobs(ctx, ns, o.Selector, o.CommitSha)
+	}
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// containsSelectedJobs returns true if the namespace contains any jobs matching the selector whatever their commit
+func (o *Options) containsSelectedJobs(ctx context.Context, ns string) (bool, error) {
+	return o.listContainsJobs(ctx, ns, o.Selector, "")
+}
+
+// listContainsJobs returns true if the namespace contains any jobs matching the selector and commit sha
+func (o *Options) listContainsJobs(ctx context.Context, ns, selector, commitSha string) (bool, error) {
+	jobList, err := o.KubeClient.BatchV1().Jobs(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+		FieldSelector: o.FieldSelector,
+	})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	for i := range jobList.Items {
+		if MatchesCommitSha(&jobList.Items[i], commitSha) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// searchDescription describes what we are looking for in the search namespaces
+func (o *Options) searchDescription() string {
+	switch {
+	case o.FromCronJob != "":
+		return "CronJob " + info(o.FromCronJob)
+	case o.Name != "":
+		return "Job " + info(o.Name)
+	case o.CommitSha != "":
+		return "the Job for commit " + info(o.CommitSha)
+	default:
+		return "jobs with selector " + info(o.Selector)
+	}
+}
@@ -0,0 +1,150 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVerifyJobSearchesNamespaces(t *testing.T) {
+	labels := map[string]string{"app": "jx-bdd"}
+
+	testCases := []struct {
+		name        string
+		jobName     string
+		selector    string
+		commitSha   string
+		objects     []runtime.Object
+		expectedNS  string
+		expectedLog string
+	}{
+		{
+			name:    "name in second namespace",
+			jobName: "bdd",
+			objects: []runtime.Object{
+				newFinishedJob("jx", "bdd", labels, batchv1.JobComplete),
+			},
+			expectedNS:  "jx",
+			expectedLog: "found Job bdd in namespace jx",
+		},
+		{
+			name:     "selector in first namespace",
+			selector: "app=jx-bdd",
+			objects: []runtime.Object{
+				newFinishedJob("jx-git-operator", "boot", labels, batchv1.JobComplete),
+				newFinishedJob("jx", "bdd", labels, batchv1.JobComplete),
+			},
+			expectedNS:  "jx-git-operator",
+			expectedLog: "found jobs with selector app=jx-bdd in namespace jx-git-operator",
+		},
+		{
+			name:     "selector ignores other jobs",
+			selector: "app=jx-bdd",
+			objects: []runtime.Object{
+				newFinishedJob("jx-git-operator", "boot", map[string]string{"app": "boot"}, batchv1.JobComplete),
+				newFinishedJob("jx", "bdd", labels, batchv1.JobComplete),
+			},
+			expectedNS:  "jx",
+			expectedLog: "found jobs with selector app=jx-bdd in namespace jx",
+		},
+		{
+			name:      "commit sha ignores jobs of other commits",
+			commitSha: "0123456789abcdef0123456789abcdef01234567",
+			objects: []runtime.Object{
+				newCommitJob("jx-git-operator", "boot-1", "fedcba9876543210fedcba9876543210fedcba98"),
+				newCommitJob("jx", "boot-2", "0123456789abcdef0123456789abcdef01234567"),
+			},
+			expectedNS:  "jx",
+			expectedLog: "found the Job for commit 0123456789abcdef0123456789abcdef01234567 in namespace jx",
+		},
+		{
+			name:      "abbreviated commit sha",
+			commitSha: "0123456",
+			objects: []runtime.Object{
+				newCommitJob("jx-git-operator", "boot-1", "fedcba9876543210fedcba9876543210fedcba98"),
+				newCommitJob("jx", "boot-2", "0123456789abcdef0123456789abcdef01234567"),
+			},
+			expectedNS:  "jx",
+			expectedLog: "found the Job for commit 0123456 in namespace jx",
+		},
+		{
+			name:      "commit sha annotation",
+			commitSha: "0123456789abcdef0123456789abcdef01234567",
+			objects: []runtime.Object{
+				newCommitJob("jx-git-operator", "boot-1", "fedcba9876543210fedcba9876543210fedcba98"),
+				newAnnotatedCommitJob("jx", "boot-2", "0123456789abcdef0123456789abcdef01234567"),
+			},
+			expectedNS:  "jx",
+			expectedLog: "found the Job for commit 0123456789abcdef0123456789abcdef01234567 in namespace jx",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = fake.NewSimpleClientset(tc.objects...)
+			o.Name = tc.jobName
+			o.Selector = tc.selector
+			o.CommitSha = tc.commitSha
+			o.Latest = tc.selector != ""
+			o.Out = &bytes.Buffer{}
+
+			var err error
+			text := logger.CaptureOutput(func() {
+				err = o.Run()
+			})
+			require.NoError(t, err, "should have verified the job")
+			assert.Equal(t, tc.expectedNS, o.Namespace, "namespace")
+			assert.Contains(t, text, tc.expectedLog)
+		})
+	}
+}
+
+func TestVerifyJobSearchesNamespacesForJobNotCreatedYet(t *testing.T) {
+	ns := "jx-git-operator"
+	commitSha := "0123456789abcdef0123456789abcdef01234567"
+
+	kubeClient := newWatchedClientset(
+		newCommitJob(ns, "boot-1", "fedcba9876543210fedcba9876543210fedcba98"),
+		newFinishedJob("jx", "bdd", map[string]string{"app": "bdd"}, batchv1.JobComplete),
+	)
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Selector = "app=jx-boot"
+	o.CommitSha = commitSha
+	o.Duration = 10 * time.Second
+	o.Out = &bytes.Buffer{}
+
+	go func() {
+		kubeClient.waitForWatches("jobs")
+		_, err := kubeClient.BatchV1().Jobs(ns).Create(context.TODO(), newCommitJob(ns, "boot-2", commitSha), metav1.CreateOptions{})
+		assert.NoError(t, err, "failed to create job")
+	}()
+
+	var err error
+	text := logger.CaptureOutput(func() {
+		err = o.Run()
+	})
+	require.NoError(t, err, "should have verified the job for the commit once it was created")
+	assert.Equal(t, ns, o.Namespace, "namespace")
+	assert.Contains(t, text, "waiting for it in namespace jx-git-operator which has jobs with selector app=jx-boot")
+}
+
+func newCommitJob(ns, name, commitSha string) *batchv1.Job {
+	return newFinishedJob(ns, name, map[string]string{"app": "jx-boot", job.CommitShaLabel: commitSha}, batchv1.JobComplete)
+}
+
+func newAnnotatedCommitJob(ns, name, commitSha string) *batchv1.Job {
+	j := newFinishedJob(ns, name, nil, batchv1.JobComplete)
+	j.Annotations = map[string]string{job.CommitShaLabel: commitSha}
+	return j
+}
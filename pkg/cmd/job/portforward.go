@@ -0,0 +1,83 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForwarder forwards a local port to a port of a pod so that we can call endpoints which only accept requests
+// from inside the pod such as the /quitquitquit endpoint of the istio agent
+type PortForwarder interface {
+	// ForwardPort forwards a free local port to the port of the pod returning the local port along with a function
+	// which stops forwarding
+	ForwardPort(ctx context.Context, ns, podName string, port int) (int, func(), error)
+}
+
+// NewKubePortForwarder creates a PortForwarder which forwards ports via the Kubernetes API server
+func NewKubePortForwarder(config *rest.Config, client kubernetes.Interface) PortForwarder {
+	return &kubePortForwarder{config: config, client: client}
+}
+
+type kubePortForwarder struct {
+	config *rest.Config
+	client kubernetes.Interface
+}
+
+// ForwardPort forwards a free local port to the port of the pod
+func (f *kubePortForwarder) ForwardPort(ctx context.Context, ns, podName string, port int) (int, func(), error) {
+	transport, upgrader, err := spdy.RoundTripperFor(f.config)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create the port forward transport: %w", err)
+	}
+	u := f.client.CoreV1().RESTClient().Post().
+		Namespace(ns).
+		Resource("pods").
+		Name(podName).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, u)
+
+	stopChan := make(chan struct{})
+	readyChan := make(chan struct{})
+	once := sync.Once{}
+	stop := func() {
+		once.Do(func() {
+			close(stopChan)
+		})
+	}
+	fw, err := portforward.New(dialer, []string{fmt.Sprintf("0:%d", port)}, stopChan, readyChan, io.Discard, io.Discard)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create the port forward to pod %s: %w", podName, err)
+	}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- fw.ForwardPorts()
+	}()
+
+	select {
+	case <-readyChan:
+	case err = <-errChan:
+		return 0, nil, fmt.Errorf("failed to forward port %d of pod %s: %w", port, podName, err)
+	case <-ctx.Done():
+		stop()
+		return 0, nil, ctx.Err()
+	}
+	ports, err := fw.GetPorts()
+	if err != nil {
+		stop()
+		return 0, nil, fmt.Errorf("failed to find the local port forwarded to port %d of pod %s: %w", port, podName, err)
+	}
+	if len(ports) == 0 {
+		stop()
+		return 0, nil, fmt.Errorf("no local port forwarded to port %d of pod %s", port, podName)
+	}
+	return int(ports[0].Local), stop, nil
+}
@@ -0,0 +1,315 @@
+package job
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+)
+
+const (
+	// PodResultJSONPrefix the prefix of a line containing a JSON test result or summary
+	PodResultJSONPrefix = "POD RESULT JSON: "
+
+	// TestStatusPassed the status of a test which passed
+	TestStatusPassed = "passed"
+
+	// TestStatusFailed the status of a test which failed
+	TestStatusFailed = "failed"
+
+	// TestStatusSkipped the status of a test which was skipped
+	TestStatusSkipped = "skipped"
+)
+
+var (
+	tapPlanRegex    = regexp.MustCompile(`^1\.\.(\d+)`)
+	tapBailOutRegex = regexp.MustCompile(`^Bail out!\s*(.*)$`)
+	tapTestRegex    = regexp.MustCompile(`^(not ok|ok)\b\s*(\d+)?\s*(?:-\s*)?([^#]*)(?:#\s*(\S+)\s*(.*))?$`)
+)
+
+// TestResults the results reported by a pod via its log
+type TestResults struct {
+	// Tests the individual test results
+	Tests []TestResult
+
+	// Summary the test counts reported by the pod if it output a summary line
+	Summary *TestSummary
+
+	// Marker the value of the last line starting with PodResultPrefix if there was one
+	Marker string
+
+	// HasMarker true if the pod output a line starting with PodResultPrefix
+	HasMarker bool
+
+	// TAPPlan the number of tests in the TAP plan if the pod output one
+	TAPPlan int
+
+	// HasTAPPlan true if the pod output a TAP plan line
+	HasTAPPlan bool
+
+	// TAPCount the number of TAP test lines the pod output
+	TAPCount int
+
+	// BailOut the reason given on the TAP 'Bail out!' line if there was one
+	BailOut string
+
+	// HasBailOut true if the pod output a TAP 'Bail out!' line
+	HasBailOut bool
+}
+
+// TestResult the result of an individual test
+type TestResult struct {
+	Name     string
+	Status   string
+	Message  string
+	Duration time.Duration
+}
+
+// TestSummary the counts of the tests
+type TestSummary struct {
+	Tests   int `json:"tests,omitempty"`
+	Passed  int `json:"passed,omitempty"`
+	Failed  int `json:"failed,omitempty"`
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// jsonResultLine the JSON format of a PodResultJSONPrefix line which either describes a single test
+// if it has a name or the summary of the tests
+type jsonResultLine struct {
+	TestSummary
+	Name     string  `json:"name,omitempty"`
+	Status   string  `json:"status,omitempty"`
+	Message  string  `json:"message,omitempty"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// ParseTestResults parses the results from the lines of a pod log. The lines can be the
+// PodResultPrefix marker, PodResultJSONPrefix JSON lines or TAP output
+func ParseTestResults(lines []string) (*TestResults, error) {
+	answer := &TestResults{}
+	var tapTests []TestResult
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(line, PodResultJSONPrefix):
+			text := strings.TrimSpace(strings.TrimPrefix(line, PodResultJSONPrefix))
+			r := &jsonResultLine{}
+			err := json.Unmarshal([]byte(text), r)
+			if err != nil {
+				return answer, fmt.Errorf("failed to parse JSON result line %s: %w", text, err)
+			}
+			if r.Name == "" {
+				summary := r.TestSummary
+				answer.Summary = &summary
+				continue
+			}
+			status, known := toTestStatus(r.Status)
+			message := r.Message
+			if !known {
+				message = strings.TrimSuffix(fmt.Sprintf("unknown test status %q: %s", r.Status, message), ": ")
+			}
+			answer.Tests = append(answer.Tests, TestResult{
+				Name:     r.Name,
+				Status:   status,
+				Message:  message,
+				Duration: time.Duration(r.Duration * float64(time.Second)),
+			})
+
+		case strings.HasPrefix(line, PodResultPrefix):
+			answer.Marker = strings.TrimSpace(strings.TrimPrefix(line, PodResultPrefix))
+			answer.HasMarker = true
+
+		case tapPlanRegex.MatchString(line):
+			count, err := strconv.Atoi(tapPlanRegex.FindStringSubmatch(line)[1])
+			if err != nil {
+				return answer, fmt.Errorf("failed to parse TAP plan %s: %w", line, err)
+			}
+			answer.TAPPlan = count
+			answer.HasTAPPlan = true
+
+		case tapBailOutRegex.MatchString(line):
+			answer.BailOut = strings.TrimSpace(tapBailOutRegex.FindStringSubmatch(line)[1])
+			answer.HasBailOut = true
+
+		default:
+			t := parseTAPLine(line)
+			if t != nil {
+				tapTests = append(tapTests, *t)
+			}
+		}
+	}
+
+	// lets only trust TAP lines if there was a TAP plan to avoid matching random log lines
+	if answer.HasTAPPlan {
+		answer.Tests = append(answer.Tests, tapTests...)
+		answer.TAPCount = len(tapTests)
+	}
+	return answer, nil
+}
+
+// ParseTerminationMessage parses the results from the termination message of a container. The message uses the
+// same format as the log though the PodResultPrefix and PodResultJSONPrefix prefixes are optional
+func ParseTerminationMessage(message string) (*TestResults, error) {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == PodResultOK || strings.HasPrefix(line, PodResultFailed):
+			line = PodResultPrefix + line
+		case strings.HasPrefix(line, "{"):
+			line = PodResultJSONPrefix + line
+		}
+		lines = append(lines, line)
+	}
+	return ParseTestResults(lines)
+}
+
+func parseTAPLine(line string) *TestResult {
+	values := tapTestRegex.FindStringSubmatch(line)
+	if values == nil {
+		return nil
+	}
+	t := &TestResult{
+		Name:   strings.TrimSpace(values[3]),
+		Status: TestStatusPassed,
+	}
+	if t.Name == "" {
+		t.Name = "test " + values[2]
+	}
+	if values[1] == "not ok" {
+		t.Status = TestStatusFailed
+	}
+	directive := strings.ToUpper(values[4])
+	if strings.HasPrefix(directive, "SKIP") || strings.HasPrefix(directive, "TODO") {
+		t.Status = TestStatusSkipped
+		t.Message = strings.TrimSpace(values[5])
+	}
+	return t
+}
+
+// toTestStatus returns the status of a test and whether the status was recognised. A test with a status we do
+// not recognise fails so that a typo or an unexpected status such as 'broken' cannot hide a failing test
+func toTestStatus(status string) (string, bool) {
+	switch strings.ToLower(status) {
+	case "pass", "passed", "ok", "success", "succeeded":
+		return TestStatusPassed, true
+	case "fail", "failed", "failure", "error":
+		return TestStatusFailed, true
+	case "skip", "skipped", "pending", "ignored":
+		return TestStatusSkipped, true
+	default:
+		return TestStatusFailed, false
+	}
+}
+
+// IsEmpty returns true if no results were reported
+func (r *TestResults) IsEmpty() bool {
+	return len(r.Tests) == 0 && r.Summary == nil && !r.HasMarker && !r.HasTAPPlan && !r.HasBailOut
+}
+
+// Counts returns the test counts using the reported summary if there was one
+func (r *TestResults) Counts() TestSummary {
+	if r.Summary != nil {
+		answer := *r.Summary
+		if answer.Tests == 0 {
+			answer.Tests = answer.Passed + answer.Failed + answer.Skipped
+		}
+		return answer
+	}
+	answer := TestSummary{
+		Tests: len(r.Tests),
+	}
+	for i := range r.Tests {
+		switch r.Tests[i].Status {
+		case TestStatusFailed:
+			answer.Failed++
+		case TestStatusSkipped:
+			answer.Skipped++
+		default:
+			answer.Passed++
+		}
+	}
+	return answer
+}
+
+// FailedTests returns the names of the tests which failed
+func (r *TestResults) FailedTests() []string {
+	var answer []string
+	for i := range r.Tests {
+		if r.Tests[i].Status == TestStatusFailed {
+			answer = append(answer, r.Tests[i].Name)
+		}
+	}
+	return answer
+}
+
+// Verify returns an error if any test failed, the pod reported a failed result or the pod did not run all of
+// the tests it said it would via a TAP plan or a summary
+func (r *TestResults) Verify(podName string) error {
+	failed := r.FailedTests()
+	if len(failed) > 0 {
+		return fmt.Errorf("pod %s has %d failed tests: %s", podName, len(failed), strings.Join(failed, ", "))
+	}
+	counts := r.Counts()
+	if counts.Failed > 0 {
+		return fmt.Errorf("pod %s has %d failed tests", podName, counts.Failed)
+	}
+	if r.HasBailOut {
+		return errors.New(strings.TrimSuffix(fmt.Sprintf("pod %s bailed out of its tests: %s", podName, r.BailOut), ": "))
+	}
+	if r.HasTAPPlan && r.TAPCount != r.TAPPlan {
+		return fmt.Errorf("pod %s ran %d tests but its TAP plan expected %d", podName, r.TAPCount, r.TAPPlan)
+	}
+	reported := counts.Passed + counts.Failed + counts.Skipped
+	if counts.Tests > reported {
+		return fmt.Errorf("pod %s reported %d tests but only %d passed, failed or skipped", podName, counts.Tests, reported)
+	}
+	if r.HasMarker {
+		if r.Marker == PodResultOK {
+			return nil
+		}
+		return fmt.Errorf("pod %s %s", podName, r.Marker)
+	}
+	if r.IsEmpty() {
+		return fmt.Errorf("pod %s did not output expected line: %s", podName, PodResultPrefix)
+	}
+	return nil
+}
+
+// WriteSummary writes a table of the tests and their counts
+func (r *TestResults) WriteSummary(out io.Writer) {
+	if len(r.Tests) > 0 {
+		t := table.CreateTable(out)
+		t.AddRow("TEST", "RESULT", "DURATION")
+		for i := range r.Tests {
+			test := &r.Tests[i]
+			t.AddRow(test.Name, colorTestStatus(test.Status), test.Duration.String())
+		}
+		t.Render()
+	}
+	counts := r.Counts()
+	if counts.Tests > 0 {
+		fmt.Fprintf(out, "\n%d tests: %s passed, %s failed, %s skipped\n\n", counts.Tests,
+			info(fmt.Sprintf("%d", counts.Passed)),
+			termcolor.ColorError(fmt.Sprintf("%d", counts.Failed)),
+			termcolor.ColorWarning(fmt.Sprintf("%d", counts.Skipped)))
+	}
+}
+
+func colorTestStatus(status string) string {
+	switch status {
+	case TestStatusFailed:
+		return termcolor.ColorError(status)
+	case TestStatusSkipped:
+		return termcolor.ColorWarning(status)
+	default:
+		return info(status)
+	}
+}
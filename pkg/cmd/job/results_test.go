@@ -0,0 +1,207 @@
+package job_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTestResults(t *testing.T) {
+	testCases := []struct {
+		name          string
+		log           string
+		expectedTests []job.TestResult
+		expectedCount job.TestSummary
+		expectedError string
+	}{
+		{
+			name:          "ok-marker",
+			log:           "some output\nPOD RESULT: OK\n",
+			expectedCount: job.TestSummary{},
+		},
+		{
+			name:          "failed-marker",
+			log:           "POD RESULT: FAILED: something went wrong\n",
+			expectedError: "pod my-pod FAILED: something went wrong",
+		},
+		{
+			name:          "last-marker-wins",
+			log:           "POD RESULT: FAILED: retrying\nPOD RESULT: OK\n",
+			expectedCount: job.TestSummary{},
+		},
+		{
+			name:          "no-result",
+			log:           "some output\nok we are done\n",
+			expectedError: "pod my-pod did not output expected line: POD RESULT: ",
+		},
+		{
+			name: "json-tests",
+			log: `POD RESULT JSON: {"name": "create-app", "status": "passed", "duration": 1.5}
+POD RESULT JSON: {"name": "promote-app", "status": "skipped"}
+POD RESULT JSON: {"name": "delete-app", "status": "fail", "message": "timed out"}
+POD RESULT: OK
+`,
+			expectedTests: []job.TestResult{
+				{Name: "create-app", Status: job.TestStatusPassed, Duration: 1500 * time.Millisecond},
+				{Name: "promote-app", Status: job.TestStatusSkipped},
+				{Name: "delete-app", Status: job.TestStatusFailed, Message: "timed out"},
+			},
+			expectedCount: job.TestSummary{Tests: 3, Passed: 1, Failed: 1, Skipped: 1},
+			expectedError: "pod my-pod has 1 failed tests: delete-app",
+		},
+		{
+			name: "json-unknown-status",
+			log: `POD RESULT JSON: {"name": "create-app", "status": "ok"}
+POD RESULT JSON: {"name": "promote-app", "status": "broken"}
+POD RESULT JSON: {"name": "delete-app", "status": "", "message": "crashed"}
+POD RESULT: OK
+`,
+			expectedTests: []job.TestResult{
+				{Name: "create-app", Status: job.TestStatusPassed},
+				{Name: "promote-app", Status: job.TestStatusFailed, Message: `unknown test status "broken"`},
+				{Name: "delete-app", Status: job.TestStatusFailed, Message: `unknown test status "": crashed`},
+			},
+			expectedCount: job.TestSummary{Tests: 3, Passed: 1, Failed: 2},
+			expectedError: "pod my-pod has 2 failed tests: promote-app, delete-app",
+		},
+		{
+			name:          "json-summary",
+			log:           `POD RESULT JSON: {"passed": 10, "failed": 2, "skipped": 1}`,
+			expectedCount: job.TestSummary{Tests: 13, Passed: 10, Failed: 2, Skipped: 1},
+			expectedError: "pod my-pod has 2 failed tests",
+		},
+		{
+			name: "tap",
+			log: `TAP version 13
+1..3
+ok 1 - create app
+not ok 2 - promote app
+ok 3 - delete app # SKIP not supported
+`,
+			expectedTests: []job.TestResult{
+				{Name: "create app", Status: job.TestStatusPassed},
+				{Name: "promote app", Status: job.TestStatusFailed},
+				{Name: "delete app", Status: job.TestStatusSkipped, Message: "not supported"},
+			},
+			expectedCount: job.TestSummary{Tests: 3, Passed: 1, Failed: 1, Skipped: 1},
+			expectedError: "pod my-pod has 1 failed tests: promote app",
+		},
+		{
+			name: "tap-short-count",
+			log: `1..3
+ok 1 - create app
+ok 2 - promote app
+`,
+			expectedTests: []job.TestResult{
+				{Name: "create app", Status: job.TestStatusPassed},
+				{Name: "promote app", Status: job.TestStatusPassed},
+			},
+			expectedCount: job.TestSummary{Tests: 2, Passed: 2},
+			expectedError: "pod my-pod ran 2 tests but its TAP plan expected 3",
+		},
+		{
+			name: "tap-bail-out",
+			log: `1..3
+ok 1 - create app
+Bail out! cluster unreachable
+`,
+			expectedTests: []job.TestResult{
+				{Name: "create app", Status: job.TestStatusPassed},
+			},
+			expectedCount: job.TestSummary{Tests: 1, Passed: 1},
+			expectedError: "pod my-pod bailed out of its tests: cluster unreachable",
+		},
+		{
+			name:          "tap-bail-out-without-plan",
+			log:           "Bail out!\nPOD RESULT: OK\n",
+			expectedError: "pod my-pod bailed out of its tests",
+		},
+		{
+			name: "tap-skip-all",
+			log:  "1..0 # SKIP no cluster\n",
+		},
+		{
+			name:          "json-summary-short-count",
+			log:           `POD RESULT JSON: {"tests": 13, "passed": 10, "skipped": 1}`,
+			expectedCount: job.TestSummary{Tests: 13, Passed: 10, Skipped: 1},
+			expectedError: "pod my-pod reported 13 tests but only 11 passed, failed or skipped",
+		},
+	}
+
+	for _, tc := range testCases {
+		results, err := job.ParseTestResults(strings.Split(tc.log, "\n"))
+		require.NoError(t, err, "failed to parse results for %s", tc.name)
+
+		assert.Equal(t, tc.expectedTests, results.Tests, "tests for %s", tc.name)
+		assert.Equal(t, tc.expectedCount, results.Counts(), "counts for %s", tc.name)
+
+		err = results.Verify("my-pod")
+		if tc.expectedError == "" {
+			assert.NoError(t, err, "verify for %s", tc.name)
+		} else {
+			require.Error(t, err, "verify for %s", tc.name)
+			assert.Equal(t, tc.expectedError, err.Error(), "verify error for %s", tc.name)
+		}
+
+		out := &bytes.Buffer{}
+		results.WriteSummary(out)
+		for _, test := range tc.expectedTests {
+			assert.Contains(t, out.String(), test.Name, "summary for %s", tc.name)
+		}
+	}
+}
+
+func TestParseTestResultsInvalidJSON(t *testing.T) {
+	_, err := job.ParseTestResults([]string{"POD RESULT JSON: {not json"})
+	require.Error(t, err, "should fail to parse invalid JSON")
+}
+
+func TestParseTerminationMessage(t *testing.T) {
+	testCases := []struct {
+		name          string
+		message       string
+		expectedCount job.TestSummary
+		expectedError string
+	}{
+		{
+			name:    "ok",
+			message: "OK",
+		},
+		{
+			name:          "failed",
+			message:       "FAILED: something went wrong\n",
+			expectedError: "pod my-pod FAILED: something went wrong",
+		},
+		{
+			name:          "prefixed",
+			message:       "POD RESULT: FAILED: bad",
+			expectedError: "pod my-pod FAILED: bad",
+		},
+		{
+			name:          "structured",
+			message:       `{"passed": 3, "failed": 1}`,
+			expectedCount: job.TestSummary{Tests: 4, Passed: 3, Failed: 1},
+			expectedError: "pod my-pod has 1 failed tests",
+		},
+	}
+
+	for _, tc := range testCases {
+		results, err := job.ParseTerminationMessage(tc.message)
+		require.NoError(t, err, "failed to parse termination message for %s", tc.name)
+		assert.False(t, results.IsEmpty(), "results for %s", tc.name)
+		assert.Equal(t, tc.expectedCount, results.Counts(), "counts for %s", tc.name)
+
+		err = results.Verify("my-pod")
+		if tc.expectedError == "" {
+			assert.NoError(t, err, "verify for %s", tc.name)
+		} else {
+			require.Error(t, err, "verify for %s", tc.name)
+			assert.Equal(t, tc.expectedError, err.Error(), "verify error for %s", tc.name)
+		}
+	}
+}
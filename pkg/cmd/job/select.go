@@ -0,0 +1,148 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// selectJob returns the name of the job to verify from the jobs sorted by GetSortedJobs using the --active, --latest,
+// --index or --commit-sha options, otherwise prompting the user to pick one
+func (o *Options) selectJob(ctx context.Context, client kubernetes.Interface, ns, selector string, jobs []batchv1.Job) (string, error) {
+	switch {
+	case o.Active:
+		return o.waitForNextJob(ctx, client, ns, selector, jobs)
+
+	case o.Latest:
+		if len(jobs) == 0 {
+			return "", fmt.Errorf("no jobs found in namespace %s with selector %s. Try --active to wait for the next job", ns, selector)
+		}
+		return jobs[0].Name, nil
+
+	case o.Index != nil:
+		index := *o.Index
+		if index < 0 || index >= len(jobs) {
+			return "", fmt.Errorf("cannot select --index %d as there are %d jobs in namespace %s with selector %s", index, len(jobs), ns, selector)
+		}
+		return jobs[index].Name, nil
+
+	case o.CommitSha != "":
+		if len(jobs) == 0 {
+			return o.waitForNextJob(ctx, client, ns, selector, jobs)
+		}
+		// if the job for the commit has been recreated lets pick the latest one
+		return jobs[0].Name, nil
+	}
+
+	var names []string
+	m := map[string]*batchv1.Job{}
+	for i := range jobs {
+		j := &jobs[i]
+		name := toJobName(j, len(jobs)-i)
+		m[name] = j
+		names = append(names, name)
+	}
+
+	name, err := o.Input.PickNameWithDefault(names, "select the Job to view:", "", "select which job you wish to log")
+	if err != nil {
+		return "", fmt.Errorf("failed to pick a job name: %w", err)
+	}
+	if name == "" || m[name] == nil {
+		return "", fmt.Errorf("no jobs to view. Try --active to wait for the next job")
+	}
+	return m[name].Name, nil
+}
+
+// waitForNextJob waits for a job matching the selectors and any commit sha which is not one of the existing jobs,
+// such as a job created after the command started, returning its name
+func (o *Options) waitForNextJob(ctx context.Context, client kubernetes.Interface, ns, selector string, existing []batchv1.Job) (string, error) {
+	existingNames := map[string]bool{}
+	for i := range existing {
+		existingNames[existing[i].Name] = true
+	}
+
+	changed := make(chan struct{}, 1)
+	notify := func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			notify()
+		},
+		UpdateFunc: func(_, _ interface{}) {
+			notify()
+		},
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		o.PollPeriod,
+		informers.WithNamespace(ns),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector
+			opts.FieldSelector = o.FieldSelector
+		}),
+	)
+	jobInformer := factory.Batch().V1().Jobs()
+	_, _ = jobInformer.Informer().AddEventHandler(handler)
+	lister := jobInformer.Lister().Jobs(ns)
+	factory.Start(stop)
+	if !cache.WaitForCacheSync(stop, jobInformer.Informer().HasSynced) {
+		return "", fmt.Errorf("timed out waiting for the cache of jobs in namespace %s to sync", ns)
+	}
+
+	if o.CommitSha != "" {
+		logger.Logger().Infof("waiting up to %s for the Job for commit %s in namespace %s with selector %s", o.Duration.String(), info(o.CommitSha), info(ns), info(selector))
+	} else {
+		logger.Logger().Infof("waiting up to %s for the next Job in namespace %s with selector %s", o.Duration.String(), info(ns), info(selector))
+	}
+	for {
+		jobList, err := lister.List(labels.Everything())
+		if err != nil {
+			return "", fmt.Errorf("failed to list jobs in namespace %s with selector %s: %w", ns, selector, err)
+		}
+		var newJobs []*batchv1.Job
+		for _, j := range jobList {
+			if !existingNames[j.Name] && MatchesCommitSha(j, o.CommitSha) {
+				newJobs = append(newJobs, j)
+			}
+		}
+		if len(newJobs) > 0 {
+			// lets pick the first job created if several are created at once
+			sort.Slice(newJobs, func(i, j int) bool {
+				return newJobs[i].CreationTimestamp.Before(&newJobs[j].CreationTimestamp)
+			})
+			jobName := newJobs[0].Name
+			logger.Logger().Infof("found new Job %s in namespace %s", info(jobName), info(ns))
+			return jobName, nil
+		}
+
+		if time.Now().After(o.timeEnd) {
+			return "", fmt.Errorf("timed out after waiting for duration %s", o.Duration.String())
+		}
+		timer := time.NewTimer(o.PollPeriod)
+		select {
+		case <-changed:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return "", ctx.Err()
+		}
+		timer.Stop()
+	}
+}
@@ -0,0 +1,166 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVerifyJobSelectsJob(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+	now := time.Now()
+
+	oldJob := newFinishedJob(ns, "bdd-1", labels, batchv1.JobFailed)
+	oldJob.CreationTimestamp = metav1.NewTime(now.Add(-time.Hour))
+	newJob := newFinishedJob(ns, "bdd-2", labels, batchv1.JobComplete)
+	newJob.CreationTimestamp = metav1.NewTime(now.Add(-time.Minute))
+
+	testCases := []struct {
+		name    string
+		latest  bool
+		index   *int
+		all     bool
+		errText string
+	}{
+		{
+			name:   "latest",
+			latest: true,
+		},
+		{
+			name:  "index of latest",
+			index: intPtr(0),
+		},
+		{
+			name:    "index of older job",
+			index:   intPtr(1),
+			errText: "bdd-1",
+		},
+		{
+			name:    "index out of range",
+			index:   intPtr(2),
+			errText: "cannot select --index 2 as there are 2 jobs",
+		},
+		{
+			name:    "conflicting selections",
+			latest:  true,
+			all:     true,
+			errText: "only one of --all, --active, --latest and --index can be specified",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = fake.NewSimpleClientset(oldJob.DeepCopy(), newJob.DeepCopy())
+			o.Namespace = ns
+			o.Selector = "app=jx-bdd"
+			o.Latest = tc.latest
+			o.Index = tc.index
+			o.All = tc.all
+			o.Out = &bytes.Buffer{}
+
+			err := o.Run()
+			if tc.errText == "" {
+				require.NoError(t, err, "should have verified the latest job")
+				return
+			}
+			require.Error(t, err, "should have failed")
+			assert.Contains(t, err.Error(), tc.errText)
+		})
+	}
+}
+
+func TestVerifyJobIndexFlag(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+
+	cmd, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(newFinishedJob(ns, "bdd-1", labels, batchv1.JobComplete))
+	o.Out = &bytes.Buffer{}
+	assert.Nil(t, o.Index, "should not select by index unless the flag is given")
+
+	cmd.SetArgs([]string{"--namespace", ns, "--selector", "app=jx-bdd", "--index", "0"})
+	err := cmd.Execute()
+	require.NoError(t, err, "should have verified the job")
+	require.NotNil(t, o.Index, "should select by index as the flag was given")
+	assert.Equal(t, 0, *o.Index)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestVerifyJobActiveWaitsForNextJob(t *testing.T) {
+	ns := "jx"
+	labels := map[string]string{"app": "jx-bdd"}
+
+	_, o := job.NewCmdVerifyJob()
+	kubeClient := newWatchedClientset(newFinishedJob(ns, "bdd-1", labels, batchv1.JobFailed))
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Selector = "app=jx-bdd"
+	o.Active = true
+	o.Duration = time.Minute
+	o.Out = &bytes.Buffer{}
+
+	go func() {
+		kubeClient.waitForWatches("jobs")
+		_, err := kubeClient.BatchV1().Jobs(ns).Create(context.TODO(), newFinishedJob(ns, "bdd-2", labels, batchv1.JobComplete), metav1.CreateOptions{})
+		assert.NoError(t, err, "failed to create job")
+	}()
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the new job rather than the existing failed job")
+}
+
+func TestVerifyJobCommitSha(t *testing.T) {
+	ns := "jx-git-operator"
+	labels := map[string]string{"app": "jx-boot"}
+
+	oldJob := newFinishedJob(ns, "boot-1", labels, batchv1.JobFailed)
+	oldJob.Labels = map[string]string{"app": "jx-boot", job.CommitShaLabel: "1111111111111111111111111111111111111111"}
+	annotatedJob := newFinishedJob(ns, "boot-2", labels, batchv1.JobComplete)
+	annotatedJob.Annotations = map[string]string{job.CommitShaLabel: "2222222222222222222222222222222222222222"}
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(oldJob, annotatedJob)
+	o.Namespace = ns
+	o.Selector = "app=jx-boot"
+	o.CommitSha = "2222222"
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the job with the commit sha annotation")
+
+	jobs, err := job.GetSortedJobs(o.KubeClient, ns, o.Selector, "", "1111111111111111111111111111111111111111")
+	require.NoError(t, err, "failed to get jobs")
+	require.Len(t, jobs, 1, "jobs for commit")
+	assert.Equal(t, "boot-1", jobs[0].Name)
+
+	kubeClient := newWatchedClientset(oldJob, annotatedJob)
+	_, o = job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.Namespace = ns
+	o.Selector = "app=jx-boot"
+	o.CommitSha = "3333333333333333333333333333333333333333"
+	o.Duration = time.Minute
+	o.Out = &bytes.Buffer{}
+
+	go func() {
+		kubeClient.waitForWatches("jobs")
+		newJob := newFinishedJob(ns, "boot-3", map[string]string{"app": "jx-boot", job.CommitShaLabel: o.CommitSha}, batchv1.JobComplete)
+		_, err := kubeClient.BatchV1().Jobs(ns).Create(context.TODO(), newJob, metav1.CreateOptions{})
+		assert.NoError(t, err, "failed to create job")
+	}()
+
+	err = o.Run()
+	require.NoError(t, err, "should have waited for the job for the commit")
+}
@@ -0,0 +1,131 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// StopSidecarsNone leaves any sidecars running once the main container has terminated
+	StopSidecarsNone = "none"
+
+	// StopSidecarsIstio asks the istio-proxy sidecar to exit via its /quitquitquit endpoint
+	StopSidecarsIstio = "istio"
+
+	// StopSidecarsDeletePod deletes the pod once the main container has terminated suspending the job first unless it
+	// will retry the failed main container so that the job does not run the pod again
+	StopSidecarsDeletePod = "delete-pod"
+
+	// istioAgentPort the port of the istio agent which serves the /quitquitquit endpoint
+	istioAgentPort = 15020
+
+	// defaultBackoffLimit the number of retries of a job if it does not specify a backoff limit
+	defaultBackoffLimit = int32(6)
+)
+
+// StopSidecarsValues the valid values of the stop sidecars option
+var StopSidecarsValues = []string{StopSidecarsNone, StopSidecarsIstio, StopSidecarsDeletePod}
+
+// mainContainerResult returns true if the main container of the pod has terminated along with an error if it failed
+func (o *Options) mainContainerResult(pod *corev1.Pod) (bool, error) {
+	status := findContainerStatus(pod, o.MainContainer, false)
+	if status == nil || status.State.Terminated == nil {
+		return false, nil
+	}
+	terminated := status.State.Terminated
+	if terminated.ExitCode == 0 {
+		logger.Logger().Infof("main container %s of pod %s has %s", info(o.MainContainer), info(pod.Name), info("Succeeded"))
+		return true, nil
+	}
+	logger.Logger().Infof("main container %s of pod %s has %s with exit code %d", info(o.MainContainer), info(pod.Name),
+		termcolor.ColorError("Failed"), terminated.ExitCode)
+	err := fmt.Errorf("main container %s of pod %s failed with exit code %d", o.MainContainer, pod.Name, terminated.ExitCode)
+	if terminated.Message != "" {
+		err = fmt.Errorf("%w: %s", err, terminated.Message)
+	}
+	return true, err
+}
+
+// canRetryMainContainer returns true if the job will create another pod once the pod whose main container has failed
+// fails. The pod only fails if its sidecars are stopped
+func (o *Options) canRetryMainContainer(w *jobWatcher) bool {
+	if o.StopSidecars == StopSidecarsNone {
+		return false
+	}
+	job, err := w.GetJob()
+	if err != nil || job == nil {
+		return false
+	}
+	backoffLimit := defaultBackoffLimit
+	if job.Spec.BackoffLimit != nil {
+		backoffLimit = *job.Spec.BackoffLimit
+	}
+	return job.Status.Failed < backoffLimit
+}
+
+// stopSidecars stops the sidecars of the pod once the main container has terminated so that the job can complete.
+// If the job will retry the failed main container the pod is left to fail so that the job creates the next attempt
+func (o *Options) stopSidecars(ctx context.Context, client kubernetes.Interface, ns, jobName string, pod *corev1.Pod, retry bool) {
+	switch o.StopSidecars {
+	case StopSidecarsIstio:
+		err := o.stopIstioSidecar(ctx, ns, pod.Name)
+		if err != nil {
+			logger.Logger().Warnf("failed to stop the istio sidecar of pod %s: %s", pod.Name, err.Error())
+			return
+		}
+		logger.Logger().Infof("stopped the istio sidecar of pod %s", info(pod.Name))
+
+	case StopSidecarsDeletePod:
+		if !retry {
+			// the job would replace the deleted pod so lets suspend it first
+			patch := []byte(`{"spec":{"suspend":true}}`)
+			_, err := client.BatchV1().Jobs(ns).Patch(ctx, jobName, types.MergePatchType, patch, metav1.PatchOptions{})
+			if err != nil {
+				logger.Logger().Warnf("failed to suspend job %s so the job may run the pod again: %s", jobName, err.Error())
+			} else {
+				logger.Logger().Infof("suspended job %s so that it does not run the pod again", info(jobName))
+			}
+		}
+		err := client.CoreV1().Pods(ns).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			logger.Logger().Warnf("failed to delete pod %s: %s", pod.Name, err.Error())
+			return
+		}
+		logger.Logger().Infof("deleted pod %s as its main container has terminated", info(pod.Name))
+	}
+}
+
+// stopIstioSidecar asks the istio agent of the pod to exit. The agent only accepts requests from localhost so the
+// request is sent via a port forward into the network namespace of the pod
+func (o *Options) stopIstioSidecar(ctx context.Context, ns, podName string) error {
+	localPort, stop, err := o.PortForwarder.ForwardPort(ctx, ns, podName, istioAgentPort)
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	u := fmt.Sprintf("http://localhost:%d/quitquitquit", localPort)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request %s: %w", u, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to POST %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("the istio agent returned status %s", resp.Status)
+	}
+	return nil
+}
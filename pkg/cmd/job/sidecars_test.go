@@ -0,0 +1,230 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestVerifyJobMainContainer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		exitCode int32
+		errText  string
+	}{
+		{
+			name: "succeeded",
+		},
+		{
+			name:     "failed",
+			exitCode: 1,
+			errText:  "main container test of pod bdd-abc failed with exit code 1: boom",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ns := "jx"
+			jobName := "bdd"
+			podName := "bdd-abc"
+
+			// the sidecar keeps the pod running after the main container has terminated
+			pod := newJobPod(ns, podName, jobName, corev1.PodRunning, tc.exitCode)
+			pod.Status.ContainerStatuses[0].State.Terminated.Message = "boom"
+			pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "istio-proxy"})
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+				Name: "istio-proxy",
+				State: corev1.ContainerState{
+					Running: &corev1.ContainerStateRunning{},
+				},
+			})
+			// the job cannot be retried so the failure of the main container fails the job
+			j := newActiveJob(ns, jobName)
+			backoffLimit := int32(0)
+			j.Spec.BackoffLimit = &backoffLimit
+			kubeClient := newWatchedClientset(j, pod)
+
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = kubeClient
+			o.LogSource = &testhelpers.FakeLogSource{
+				Logs: map[string]string{
+					podName + "/test":        "testing\n",
+					podName + "/istio-proxy": "proxying\n",
+				},
+			}
+			o.Namespace = ns
+			o.Name = jobName
+			o.MainContainer = "test"
+			o.StopSidecars = job.StopSidecarsDeletePod
+			o.Duration = 10 * time.Second
+			out := &bytes.Buffer{}
+			o.Out = out
+
+			err := o.Run()
+			if tc.errText == "" {
+				require.NoError(t, err, "should have verified the main container")
+			} else {
+				require.Error(t, err, "should have failed")
+				assert.Equal(t, tc.errText, err.Error())
+			}
+			assert.Contains(t, out.String(), "testing\n", "should tail the main container")
+			assert.NotContains(t, out.String(), "proxying", "should only tail the main container")
+
+			_, err = kubeClient.CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			assert.True(t, apierrors.IsNotFound(err), "the pod should have been deleted but got %v", err)
+
+			j, err = kubeClient.BatchV1().Jobs(ns).Get(context.TODO(), jobName, metav1.GetOptions{})
+			require.NoError(t, err, "failed to get job %s", jobName)
+			assert.True(t, j.Spec.Suspend != nil && *j.Spec.Suspend, "the job should have been suspended so it does not run the pod again")
+		})
+	}
+}
+
+func TestVerifyJobStopIstioSidecar(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err, "failed to parse server URL")
+	serverPort, err := strconv.Atoi(serverURL.Port())
+	require.NoError(t, err, "failed to parse server port")
+
+	pod := newJobPod(ns, podName, jobName, corev1.PodRunning, 0)
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "istio-proxy"})
+	pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+		Name: "istio-proxy",
+		State: corev1.ContainerState{
+			Running: &corev1.ContainerStateRunning{},
+		},
+	})
+
+	forwarder := &fakePortForwarder{localPort: serverPort}
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = newWatchedClientset(newActiveJob(ns, jobName), pod)
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{podName + "/test": "testing\n"},
+	}
+	o.PortForwarder = forwarder
+	o.Namespace = ns
+	o.Name = jobName
+	o.MainContainer = "test"
+	o.StopSidecars = job.StopSidecarsIstio
+	o.Duration = 10 * time.Second
+	o.Out = &bytes.Buffer{}
+
+	err = o.Run()
+	require.NoError(t, err, "should have verified the main container")
+	assert.Equal(t, []string{"jx/bdd-abc:15020"}, forwarder.forwarded, "should have forwarded the istio agent port")
+	assert.Equal(t, []string{"POST /quitquitquit"}, requests, "should have asked the istio agent to exit")
+	assert.True(t, forwarder.stopped, "should have stopped the port forward")
+}
+
+// fakePortForwarder forwards every port to the local port of a test server
+type fakePortForwarder struct {
+	localPort int
+	forwarded []string
+	stopped   bool
+}
+
+func (f *fakePortForwarder) ForwardPort(_ context.Context, ns, podName string, port int) (int, func(), error) {
+	f.forwarded = append(f.forwarded, fmt.Sprintf("%s/%s:%d", ns, podName, port))
+	return f.localPort, func() {
+		f.stopped = true
+	}, nil
+}
+
+func TestVerifyJobMainContainerRetries(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	newSidecarPod := func(podName string, exitCode int32) *corev1.Pod {
+		pod := newJobPod(ns, podName, jobName, corev1.PodRunning, exitCode)
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "istio-proxy"})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name: "istio-proxy",
+			State: corev1.ContainerState{
+				Running: &corev1.ContainerStateRunning{},
+			},
+		})
+		return pod
+	}
+
+	j := newActiveJob(ns, jobName)
+	backoffLimit := int32(1)
+	j.Spec.BackoffLimit = &backoffLimit
+	kubeClient := newWatchedClientset(j, newSidecarPod("bdd-abc", 1))
+	kubeClient.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.(k8stesting.DeleteAction).GetName() != "bdd-abc" {
+			return false, nil, nil
+		}
+		// lets fail the first attempt and create the retry like the job controller
+		tracker := kubeClient.Tracker()
+		current, err := tracker.Get(batchv1.SchemeGroupVersion.WithResource("jobs"), ns, jobName)
+		if err != nil {
+			return true, nil, err
+		}
+		assert.Nil(t, current.(*batchv1.Job).Spec.Suspend, "the job should not be suspended while it can retry")
+		failed := j.DeepCopy()
+		failed.Status.Failed = 1
+		err = tracker.Update(batchv1.SchemeGroupVersion.WithResource("jobs"), failed, ns)
+		if err != nil {
+			return true, nil, err
+		}
+		return false, nil, tracker.Add(newSidecarPod("bdd-def", 0))
+	})
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			"bdd-abc/test": "attempt 1\n",
+			"bdd-def/test": "attempt 2\n",
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.MainContainer = "test"
+	o.StopSidecars = job.StopSidecarsDeletePod
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the retry of the job")
+	assert.Equal(t, "attempt 1\nattempt 2\n", out.String(), "should tail both attempts")
+}
+
+func TestVerifyJobStopSidecarsRequiresMainContainer(t *testing.T) {
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset()
+	o.Namespace = "jx"
+	o.Name = "bdd"
+	o.StopSidecars = job.StopSidecarsIstio
+
+	err := o.Run()
+	require.Error(t, err, "should fail without --main-container")
+	assert.Contains(t, err.Error(), "--stop-sidecars can only be used with --main-container")
+}
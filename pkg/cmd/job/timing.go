@@ -0,0 +1,188 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// startHeartbeat logs the current phase of the job whenever nothing has been output for the heartbeat period so
+// that CI systems which kill quiet steps know we are still waiting. The returned function stops the heartbeat
+func (o *Options) startHeartbeat(ctx context.Context, w *jobWatcher) func() {
+	if o.Heartbeat <= 0 {
+		return func() {}
+	}
+	start := time.Now()
+	o.markOutput()
+	done := make(chan struct{})
+	go func() {
+		timer := time.NewTimer(o.Heartbeat)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				quiet := time.Since(time.Unix(0, o.lastOutput.Load()))
+				if quiet >= o.Heartbeat {
+					logger.Logger().Infof("job %s is %s after %s", info(w.jobName), info(currentPhase(w)), time.Since(start).Round(time.Second).String())
+					o.markOutput()
+					quiet = 0
+				}
+				timer.Reset(o.Heartbeat - quiet)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// markOutput records that we have just output something so that the heartbeat is only logged when we are quiet
+func (o *Options) markOutput() {
+	o.lastOutput.Store(time.Now().UnixNano())
+}
+
+// currentPhase returns a description of what the job is currently doing
+func currentPhase(w *jobWatcher) string {
+	job, err := w.GetJob()
+	if err != nil || job == nil {
+		return "waiting for the job to be created"
+	}
+	podList, err := w.ListPods()
+	if err != nil || len(podList) == 0 {
+		return "waiting for a pod to be created"
+	}
+	pod := podList[len(podList)-1]
+	switch pod.Status.Phase {
+	case corev1.PodRunning:
+		return fmt.Sprintf("running pod %s", pod.Name)
+	case corev1.PodPending:
+		if !isPodScheduled(pod) {
+			return fmt.Sprintf("waiting for pod %s to be scheduled", pod.Name)
+		}
+		// sidecar init containers keep running while the containers start so lets check the containers first
+		for _, c := range []struct {
+			kind     string
+			statuses []corev1.ContainerStatus
+		}{
+			{"container", pod.Status.ContainerStatuses},
+			{"init container", pod.Status.InitContainerStatuses},
+		} {
+			for i := range c.statuses {
+				s := &c.statuses[i]
+				if s.State.Running != nil {
+					return fmt.Sprintf("running %s %s of pod %s", c.kind, s.Name, pod.Name)
+				}
+				if s.State.Waiting != nil && strings.Contains(s.State.Waiting.Reason, "Image") {
+					return fmt.Sprintf("pulling the image of %s %s of pod %s (%s)", c.kind, s.Name, pod.Name, s.State.Waiting.Reason)
+				}
+			}
+		}
+		return fmt.Sprintf("pulling images and starting the containers of pod %s", pod.Name)
+	default:
+		return fmt.Sprintf("waiting for the job to complete as pod %s has %s", pod.Name, pod.Status.Phase)
+	}
+}
+
+func isPodScheduled(pod *corev1.Pod) bool {
+	c := findPodCondition(pod, corev1.PodScheduled)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
+func findPodCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// jobTiming how long each phase of the last attempt of a job took
+type jobTiming struct {
+	create          time.Duration
+	schedule        time.Duration
+	startContainers time.Duration
+	run             time.Duration
+}
+
+// String returns a readable description of the timing of the phases which could be determined
+func (t *jobTiming) String() string {
+	var parts []string
+	add := func(name string, d time.Duration) {
+		if d > 0 {
+			parts = append(parts, fmt.Sprintf("%s %s", name, info(d.Round(time.Second).String())))
+		}
+	}
+	add("create", t.create)
+	add("schedule", t.schedule)
+	add("start containers", t.startContainers)
+	add("run", t.run)
+	return strings.Join(parts, ", ")
+}
+
+// getJobTiming returns the timing of the phases of the job using its last pod
+func getJobTiming(job *batchv1.Job, pod *corev1.Pod) *jobTiming {
+	created := pod.CreationTimestamp.Time
+	var scheduled, started, finished time.Time
+	c := findPodCondition(pod, corev1.PodScheduled)
+	if c != nil && c.Status == corev1.ConditionTrue {
+		scheduled = c.LastTransitionTime.Time
+	}
+	for _, statuses := range [][]corev1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for i := range statuses {
+			s := &statuses[i]
+			var startedAt metav1.Time
+			switch {
+			case s.State.Running != nil:
+				startedAt = s.State.Running.StartedAt
+			case s.State.Terminated != nil:
+				startedAt = s.State.Terminated.StartedAt
+				if s.State.Terminated.FinishedAt.After(finished) {
+					finished = s.State.Terminated.FinishedAt.Time
+				}
+			}
+			if !startedAt.IsZero() && (started.IsZero() || startedAt.Time.Before(started)) {
+				started = startedAt.Time
+			}
+		}
+	}
+	if finished.IsZero() && !started.IsZero() {
+		finished = time.Now()
+	}
+	return &jobTiming{
+		create:          between(job.CreationTimestamp.Time, created),
+		schedule:        between(created, scheduled),
+		startContainers: between(scheduled, started),
+		run:             between(started, finished),
+	}
+}
+
+// between returns the duration between the times or 0 if either is unknown
+func between(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// reportJobTiming logs how long each phase of the last attempt of the job took using its pods sorted by creation
+func (o *Options) reportJobTiming(job *batchv1.Job, podItems []corev1.Pod) {
+	if len(podItems) == 0 {
+		return
+	}
+	timing := getJobTiming(job, &podItems[len(podItems)-1]).String()
+	if timing != "" {
+		logger.Logger().Infof("timing of job %s: %s", info(job.Name), timing)
+	}
+}
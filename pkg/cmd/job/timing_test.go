@@ -0,0 +1,185 @@
+package job_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestVerifyJobHeartbeat(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+
+	unscheduled := newJobPod(ns, jobName+"-abc", jobName, corev1.PodPending, 0)
+	unscheduled.Status.ContainerStatuses = nil
+	unscheduled.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:   corev1.PodScheduled,
+			Status: corev1.ConditionFalse,
+		},
+	}
+
+	// the sidecar init container is still running once the test container has started
+	starting := newJobPod(ns, jobName+"-abc", jobName, corev1.PodPending, 0)
+	starting.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:   corev1.PodScheduled,
+			Status: corev1.ConditionTrue,
+		},
+	}
+	running := corev1.ContainerState{
+		Running: &corev1.ContainerStateRunning{},
+	}
+	starting.Status.InitContainerStatuses = []corev1.ContainerStatus{
+		{
+			Name:  "sidecar",
+			State: running,
+		},
+	}
+	starting.Status.ContainerStatuses[0].State = running
+
+	testCases := []struct {
+		name     string
+		pod      *corev1.Pod
+		expected string
+	}{
+		{
+			name:     "unscheduled",
+			pod:      unscheduled,
+			expected: "job bdd is waiting for pod bdd-abc to be scheduled after",
+		},
+		{
+			name:     "container-running",
+			pod:      starting,
+			expected: "job bdd is running container test of pod bdd-abc after",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, o := job.NewCmdVerifyJob()
+			o.KubeClient = fake.NewSimpleClientset(newActiveJob(ns, jobName), tc.pod)
+			o.Namespace = ns
+			o.Name = jobName
+			o.Heartbeat = 20 * time.Millisecond
+			o.Out = &bytes.Buffer{}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+
+			var err error
+			text := logger.CaptureOutput(func() {
+				err = o.RunWithContext(ctx)
+			})
+			require.Error(t, err, "should have been cancelled")
+			assert.Contains(t, text, tc.expected)
+		})
+	}
+}
+
+func TestVerifyJobHeartbeatOnlyWhenQuiet(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	podName := "bdd-abc"
+
+	kubeClient := newWatchedClientset(newActiveJob(ns, jobName), newRunningJobPod(ns, podName, jobName))
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = kubeClient
+	o.LogSource = &streamingLogSource{
+		lines:    40,
+		interval: 10 * time.Millisecond,
+		onEnd: func() {
+			completeJob(t, kubeClient, ns, jobName, podName)
+		},
+	}
+	o.Namespace = ns
+	o.Name = jobName
+	o.Heartbeat = 100 * time.Millisecond
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	var err error
+	text := logger.CaptureOutput(func() {
+		err = o.Run()
+	})
+	require.NoError(t, err, "should have verified the job")
+	assert.Contains(t, out.String(), "line 39\n", "should have tailed the log")
+	assert.NotContains(t, text, "job bdd is running", "should not log a heartbeat while the log is streaming")
+}
+
+// streamingLogSource writes a line of the log at each interval calling onEnd before the log ends
+type streamingLogSource struct {
+	lines    int
+	interval time.Duration
+	onEnd    func()
+	once     sync.Once
+}
+
+func (s *streamingLogSource) OpenLog(ctx context.Context, _, _ string, _ *corev1.PodLogOptions) (io.ReadCloser, error) {
+	r, w := io.Pipe()
+	go func() {
+		for i := 0; i < s.lines; i++ {
+			select {
+			case <-ctx.Done():
+				w.CloseWithError(ctx.Err())
+				return
+			case <-time.After(s.interval):
+			}
+			fmt.Fprintf(w, "line %d\n", i)
+		}
+		s.once.Do(s.onEnd)
+		w.Close()
+	}()
+	return r, nil
+}
+
+func TestVerifyJobTiming(t *testing.T) {
+	ns := "jx"
+	jobName := "bdd"
+	created := time.Now().Add(-time.Hour).Truncate(time.Second)
+	at := func(d time.Duration) metav1.Time {
+		return metav1.NewTime(created.Add(d))
+	}
+
+	j := newFinishedJob(ns, jobName, nil, batchv1.JobComplete)
+	j.CreationTimestamp = at(0)
+	pod := newJobPod(ns, jobName+"-abc", jobName, corev1.PodSucceeded, 0)
+	pod.CreationTimestamp = at(2 * time.Second)
+	pod.Status.Conditions = []corev1.PodCondition{
+		{
+			Type:               corev1.PodScheduled,
+			Status:             corev1.ConditionTrue,
+			LastTransitionTime: at(5 * time.Second),
+		},
+	}
+	terminated := pod.Status.ContainerStatuses[0].State.Terminated
+	terminated.StartedAt = at(15 * time.Second)
+	terminated.FinishedAt = at(75 * time.Second)
+
+	_, o := job.NewCmdVerifyJob()
+	o.KubeClient = fake.NewSimpleClientset(j, pod)
+	o.Namespace = ns
+	o.Name = jobName
+	o.Out = &bytes.Buffer{}
+
+	var err error
+	text := logger.CaptureOutput(func() {
+		err = o.Run()
+	})
+	require.NoError(t, err, "should have verified the job")
+	assert.Contains(t, text, "timing of job bdd: create 2s, schedule 3s, start containers 10s, run 1m0s")
+}
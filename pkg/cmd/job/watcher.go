@@ -0,0 +1,190 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	batchlisters "k8s.io/client-go/listers/batch/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// jobWatcher uses informers scoped to a single job and its pods so that we react to changes
+// as they happen rather than polling the API server
+type jobWatcher struct {
+	jobName     string
+	jobLister   batchlisters.JobNamespaceLister
+	podLister   corelisters.PodNamespaceLister
+	podSelector labels.Selector
+	ignoredPods map[string]bool
+	changed     chan struct{}
+	stop        chan struct{}
+	lock        sync.Mutex
+}
+
+// newJobWatcher creates a watcher of the job with the given name and, if the pod selector is not empty, the pods
+// matching the selector. The poll period is used as the resync period of the informers
+func newJobWatcher(client kubernetes.Interface, ns, jobName, podSelector string, pollPeriod time.Duration) (*jobWatcher, error) {
+	w := &jobWatcher{
+		jobName:     jobName,
+		ignoredPods: map[string]bool{},
+		changed:     make(chan struct{}),
+		stop:        make(chan struct{}),
+	}
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: func(_ interface{}) {
+			w.notify()
+		},
+		UpdateFunc: func(_, _ interface{}) {
+			w.notify()
+		},
+		DeleteFunc: func(_ interface{}) {
+			w.notify()
+		},
+	}
+
+	jobFactory := informers.NewSharedInformerFactoryWithOptions(
+		client,
+		pollPeriod,
+		informers.WithNamespace(ns),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = "metadata.name=" + jobName
+		}),
+	)
+	jobInformer := jobFactory.Batch().V1().Jobs()
+	_, _ = jobInformer.Informer().AddEventHandler(handler)
+	w.jobLister = jobInformer.Lister().Jobs(ns)
+	synced := []cache.InformerSynced{jobInformer.Informer().HasSynced}
+	jobFactory.Start(w.stop)
+
+	if podSelector != "" {
+		var err error
+		w.podSelector, err = labels.Parse(podSelector)
+		if err != nil {
+			w.Stop()
+			return nil, fmt.Errorf("failed to parse pod selector %s: %w", podSelector, err)
+		}
+
+		podFactory := informers.NewSharedInformerFactoryWithOptions(
+			client,
+			pollPeriod,
+			informers.WithNamespace(ns),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.LabelSelector = podSelector
+			}),
+		)
+		podInformer := podFactory.Core().V1().Pods()
+		_, _ = podInformer.Informer().AddEventHandler(handler)
+		w.podLister = podInformer.Lister().Pods(ns)
+		synced = append(synced, podInformer.Informer().HasSynced)
+		podFactory.Start(w.stop)
+	}
+
+	if !cache.WaitForCacheSync(w.stop, synced...) {
+		w.Stop()
+		return nil, fmt.Errorf("timed out waiting for the caches of job %s to sync", jobName)
+	}
+	return w, nil
+}
+
+// Stop stops the informers
+func (w *jobWatcher) Stop() {
+	close(w.stop)
+}
+
+// notify wakes up all the goroutines waiting for a change
+func (w *jobWatcher) notify() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+// Wait waits until the job or one of its pods changes or the timeout expires returning an error if the context is cancelled
+func (w *jobWatcher) Wait(ctx context.Context, timeout time.Duration) error {
+	w.lock.Lock()
+	changed := w.changed
+	w.lock.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-changed:
+	case <-timer.C:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// GetJob returns the job or nil if it does not exist
+func (w *jobWatcher) GetJob() (*batchv1.Job, error) {
+	job, err := w.jobLister.Get(w.jobName)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// GetPod returns the pod with the given name or nil if it does not exist
+func (w *jobWatcher) GetPod(name string) (*corev1.Pod, error) {
+	if w.podLister == nil {
+		return nil, nil
+	}
+	pod, err := w.podLister.Get(name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pod, nil
+}
+
+// IgnorePod excludes the pod from the results of ListPods such as when we have finished with it
+func (w *jobWatcher) IgnorePod(name string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.ignoredPods[name] = true
+}
+
+// ListPods returns the pods matching the selector which are not ignored sorted by creation time
+func (w *jobWatcher) ListPods() ([]*corev1.Pod, error) {
+	if w.podLister == nil {
+		return nil, nil
+	}
+	podList, err := w.podLister.List(w.podSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	w.lock.Lock()
+	var answer []*corev1.Pod
+	for _, pod := range podList {
+		if !w.ignoredPods[pod.Name] {
+			answer = append(answer, pod)
+		}
+	}
+	w.lock.Unlock()
+
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].CreationTimestamp.Before(&answer[j].CreationTimestamp)
+	})
+	return answer, nil
+}
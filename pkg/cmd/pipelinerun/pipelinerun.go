@@ -0,0 +1,294 @@
+package pipelinerun
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/podlogs"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/signals"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// LabelPipelineTask the label Tekton adds to the TaskRuns of a PipelineRun with the name of the task in the pipeline
+	LabelPipelineTask = "tekton.dev/pipelineTask"
+
+	// tektonGroup the API group of the Tekton resources
+	tektonGroup = "tekton.dev"
+)
+
+// Options the options for verifying a Tekton PipelineRun
+type Options struct {
+	options.BaseOptions
+
+	Namespace     string
+	Name          string
+	Selector      string
+	APIVersion    string
+	Duration      time.Duration
+	PollPeriod    time.Duration
+	NoTail        bool
+	Out           io.Writer
+	KubeClient    kubernetes.Interface
+	DynamicClient dynamic.Interface
+	LogSource     podlogs.LogSource
+	timeEnd       time.Time
+	tailed        map[string]bool
+}
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Verifies that a Tekton PipelineRun succeeds tailing the log of each step of each TaskRun as it executes
+
+		The PipelineRun is found by name or by selector, in which case the most recently created PipelineRun is used. Once it completes the status of each task is reported and the command fails if the PipelineRun failed.
+`)
+
+	cmdExample = templates.Examples(`
+		# verify the PipelineRun succeeds
+		jx verify pipelinerun --name my-pipeline-run-abc
+
+		# verify the latest PipelineRun of a repository succeeds
+		jx verify pipelinerun -l lighthouse.jenkins-x.io/refs.repo=myrepo
+`)
+)
+
+// NewCmdVerifyPipelineRun creates the new command
+func NewCmdVerifyPipelineRun() (*cobra.Command, *Options) {
+	o := &Options{}
+	command := &cobra.Command{
+		Use:     "pipelinerun",
+		Short:   "Verifies that a Tekton PipelineRun succeeds tailing the log of each step as it executes",
+		Aliases: []string{"pipelineruns", "pr"},
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			signals.RunUntilSignalled(o.RunWithContext)
+		},
+	}
+	command.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace of the PipelineRun. If not specified the current namespace is used")
+	command.Flags().StringVarP(&o.Name, "name", "", "", "the name of the PipelineRun to verify")
+	command.Flags().StringVarP(&o.Selector, "selector", "l", "", "the selector of the PipelineRun. The most recently created PipelineRun matching the selector is verified")
+	command.Flags().StringVarP(&o.APIVersion, "api-version", "", "v1", "the version of the "+tektonGroup+" API to use")
+	command.Flags().DurationVarP(&o.Duration, "duration", "d", time.Minute*30, "how long to wait for the PipelineRun to be created and complete")
+	command.Flags().DurationVarP(&o.PollPeriod, "poll", "", time.Second*2, "the period between polls of the PipelineRun and its TaskRuns")
+	command.Flags().BoolVarP(&o.NoTail, "no-tail", "", false, "disables tailing the logs of the steps")
+
+	o.BaseOptions.AddBaseFlags(command)
+	return command, o
+}
+
+// Validate checks the PipelineRun is specified and lazily creates the kubernetes, dynamic and log clients
+func (o *Options) Validate() error {
+	if o.Name == "" && o.Selector == "" {
+		return options.MissingOption("name")
+	}
+	if o.APIVersion == "" {
+		o.APIVersion = "v1"
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+
+	var err error
+	o.KubeClient, err = kube.LazyCreateKubeClientWithMandatory(o.KubeClient, true)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	o.DynamicClient, err = kube.LazyCreateDynamicClient(o.DynamicClient)
+	if err != nil {
+		return fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	if o.LogSource == nil {
+		o.LogSource = podlogs.NewKubeLogSource(o.KubeClient)
+	}
+	if o.Namespace == "" {
+		o.Namespace, err = kubeclient.CurrentNamespace()
+		if err != nil {
+			return fmt.Errorf("failed to detect current namespace. Try supply --namespace: %w", err)
+		}
+	}
+	o.tailed = map[string]bool{}
+	o.timeEnd = time.Now().Add(o.Duration)
+	return nil
+}
+
+// Run runs the command
+func (o *Options) Run() error {
+	return o.RunWithContext(context.Background())
+}
+
+// RunWithContext runs the command until the PipelineRun completes or the context is cancelled
+func (o *Options) RunWithContext(ctx context.Context) error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	ns := o.Namespace
+
+	pr, err := o.waitForPipelineRun(ctx, ns)
+	if err != nil {
+		return err
+	}
+	name := pr.GetName()
+	logger.Logger().Infof("verifying PipelineRun %s in namespace %s", info(name), info(ns))
+
+	var taskRuns []*unstructured.Unstructured
+	for {
+		pr, err = o.pipelineRuns(ns).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get PipelineRun %s in namespace %s: %w", name, ns, err)
+		}
+		status, _, _ := succeededCondition(pr)
+		complete := status == string(metav1.ConditionTrue) || status == string(metav1.ConditionFalse)
+
+		taskRuns, err = o.getTaskRuns(ctx, ns, name)
+		if err != nil {
+			return err
+		}
+		if !o.NoTail {
+			for _, tr := range taskRuns {
+				err = o.tailTaskRun(ctx, ns, tr)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if complete {
+			break
+		}
+
+		if time.Now().After(o.timeEnd) {
+			return fmt.Errorf("timed out after waiting for duration %s for PipelineRun %s to complete", o.Duration.String(), name)
+		}
+		err = sleep(ctx, o.PollPeriod)
+		if err != nil {
+			return err
+		}
+	}
+
+	o.reportTaskResults(getTaskResults(pr, taskRuns))
+
+	status, reason, message := succeededCondition(pr)
+	if status == string(metav1.ConditionFalse) {
+		return fmt.Errorf("PipelineRun %s failed with reason %s: %s", name, reason, message)
+	}
+	logger.Logger().Infof("PipelineRun %s has %s", info(name), info("Succeeded"))
+	return nil
+}
+
+// waitForPipelineRun waits for the PipelineRun with the name, or the latest PipelineRun matching the selector, to exist
+func (o *Options) waitForPipelineRun(ctx context.Context, ns string) (*unstructured.Unstructured, error) {
+	logged := false
+	for {
+		pr, err := o.findPipelineRun(ctx, ns)
+		if err != nil {
+			return nil, err
+		}
+		if pr != nil {
+			return pr, nil
+		}
+
+		if !logged {
+			logged = true
+			if o.Name != "" {
+				logger.Logger().Infof("waiting up to %s for the PipelineRun %s to be created", o.Duration.String(), info(o.Name))
+			} else {
+				logger.Logger().Infof("waiting up to %s for a PipelineRun with selector %s to be created", o.Duration.String(), info(o.Selector))
+			}
+		}
+		if time.Now().After(o.timeEnd) {
+			return nil, fmt.Errorf("timed out after waiting for duration %s for the PipelineRun to be created", o.Duration.String())
+		}
+		err = sleep(ctx, o.PollPeriod)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// findPipelineRun returns the PipelineRun with the name, or the latest PipelineRun matching the selector, or nil if there is none
+func (o *Options) findPipelineRun(ctx context.Context, ns string) (*unstructured.Unstructured, error) {
+	if o.Name != "" {
+		pr, err := o.pipelineRuns(ns).Get(ctx, o.Name, metav1.GetOptions{})
+		if err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("failed to get PipelineRun %s in namespace %s: %w", o.Name, ns, err)
+		}
+		return pr, nil
+	}
+
+	list, err := o.pipelineRuns(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: o.Selector,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list PipelineRuns in namespace %s with selector %s: %w", ns, o.Selector, err)
+	}
+	if list == nil || len(list.Items) == 0 {
+		return nil, nil
+	}
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		t1 := items[i].GetCreationTimestamp()
+		t2 := items[j].GetCreationTimestamp()
+		return t2.Before(&t1)
+	})
+	return &items[0], nil
+}
+
+func (o *Options) pipelineRuns(ns string) dynamic.ResourceInterface {
+	return o.DynamicClient.Resource(o.resource("pipelineruns")).Namespace(ns)
+}
+
+func (o *Options) resource(name string) schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: tektonGroup, Version: o.APIVersion, Resource: name}
+}
+
+// succeededCondition returns the status, reason and message of the Succeeded condition of the Tekton resource
+func succeededCondition(u *unstructured.Unstructured) (status, reason, message string) {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != "Succeeded" {
+			continue
+		}
+		status, _, _ = unstructured.NestedString(m, "status")
+		reason, _, _ = unstructured.NestedString(m, "reason")
+		message, _, _ = unstructured.NestedString(m, "message")
+		return status, reason, message
+	}
+	return "", "", ""
+}
+
+// sleep waits for the duration returning an error if the context is cancelled
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,223 @@
+package pipelinerun_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/pipelinerun"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/internal/testhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/builds"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var (
+	pipelineRunResource = schema.GroupVersionResource{Group: "tekton.dev", Version: "v1", Resource: "pipelineruns"}
+	taskRunResource     = schema.GroupVersionResource{Group: "tekton.dev", Version: "v1", Resource: "taskruns"}
+)
+
+func TestVerifyPipelineRunFailed(t *testing.T) {
+	ns := "jx"
+	prName := "build-1"
+
+	pr := newPipelineRun(ns, prName, "False", "Failed", "Tasks Completed: 2 (Failed: 1, Cancelled 0), Skipped: 1")
+	require.NoError(t, unstructured.SetNestedSlice(pr.Object, []interface{}{
+		map[string]interface{}{"name": "deploy", "reason": "PipelineRun Finally had Failed"},
+	}, "status", "skippedTasks"))
+
+	_, o := pipelinerun.NewCmdVerifyPipelineRun()
+	o.DynamicClient = newDynamicClient(
+		pr,
+		newTaskRun(ns, prName, "test", "True", "Succeeded", "", "2024-01-01T10:02:00Z"),
+		newTaskRun(ns, prName, "compile", "True", "Succeeded", "", "2024-01-01T10:00:00Z"),
+		newTaskRun(ns, prName, "lint", "False", "Failed", `"step-lint" exited with code 1`, "2024-01-01T10:01:00Z"),
+	)
+	o.KubeClient = fake.NewSimpleClientset(
+		newTaskPod(ns, prName+"-compile-pod", "checkout", "build"),
+		newTaskPod(ns, prName+"-lint-pod", "lint"),
+		newTaskPod(ns, prName+"-test-pod", "test"),
+	)
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			"build-1-compile-pod/step-checkout": "cloning\n",
+			"build-1-compile-pod/step-build":    "compiling\n",
+			"build-1-lint-pod/step-lint":        "lint error\n",
+			"build-1-test-pod/step-test":        "tests passed\n",
+			"build-1-compile-pod/sidecar":       "should not be tailed\n",
+		},
+	}
+	o.Namespace = ns
+	o.Name = prName
+	o.Duration = 10 * time.Second
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as the PipelineRun failed")
+	assert.Contains(t, err.Error(), "PipelineRun build-1 failed with reason Failed")
+
+	text := out.String()
+	t.Logf("output:\n%s\n", text)
+	assertInOrder(t, text, "[compile:checkout] cloning", "[compile:build] compiling", "[lint:lint] lint error", "[test:test] tests passed")
+	assert.NotContains(t, text, "should not be tailed", "should only tail the step containers")
+	assert.Regexp(t, `lint\s+build-1-lint\s+\S*Failed\S*\s+1m0s`, text, "should report the failed task")
+	assert.Regexp(t, `deploy\s+\S*Skipped`, text, "should report the skipped task")
+}
+
+func TestVerifyPipelineRunWaitsForCompletion(t *testing.T) {
+	ns := "jx"
+	prName := "build-2"
+
+	oldPR := newPipelineRun(ns, "build-1", "False", "Failed", "")
+	oldPR.SetCreationTimestamp(metav1.NewTime(time.Now().Add(-time.Hour)))
+	pr := newPipelineRun(ns, prName, "Unknown", "Running", "")
+	pr.SetCreationTimestamp(metav1.NewTime(time.Now()))
+
+	dynamicClient := newDynamicClient(
+		oldPR,
+		pr,
+		newTaskRun(ns, prName, "compile", "Unknown", "Running", "", "2024-01-01T10:00:00Z"),
+	)
+	_, o := pipelinerun.NewCmdVerifyPipelineRun()
+	o.DynamicClient = dynamicClient
+	o.KubeClient = fake.NewSimpleClientset(newTaskPod(ns, prName+"-compile-pod", "build"))
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			"build-2-compile-pod/step-build": "compiling\n",
+		},
+		OnFollow: func(_, _ string) {
+			// lets complete the PipelineRun once we have tailed the step
+			ctx := context.TODO()
+			for _, r := range []struct {
+				resource schema.GroupVersionResource
+				name     string
+			}{
+				{taskRunResource, prName + "-compile"},
+				{pipelineRunResource, prName},
+			} {
+				u, err := dynamicClient.Resource(r.resource).Namespace(ns).Get(ctx, r.name, metav1.GetOptions{})
+				require.NoError(t, err, "failed to get %s", r.name)
+				setSucceededCondition(u, "True", "Succeeded", "")
+				require.NoError(t, unstructured.SetNestedField(u.Object, "2024-01-01T10:03:00Z", "status", "completionTime"))
+				_, err = dynamicClient.Resource(r.resource).Namespace(ns).Update(ctx, u, metav1.UpdateOptions{})
+				require.NoError(t, err, "failed to update %s", r.name)
+			}
+		},
+	}
+	o.Namespace = ns
+	o.Selector = "app=my-app"
+	o.Duration = 10 * time.Second
+	o.PollPeriod = 10 * time.Millisecond
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.NoError(t, err, "should have verified the latest PipelineRun")
+
+	text := out.String()
+	t.Logf("output:\n%s\n", text)
+	assert.Equal(t, 1, strings.Count(text, "[compile:build] compiling"), "should tail the step once")
+	assert.Regexp(t, `compile\s+build-2-compile\s+\S*Succeeded\S*\s+3m0s`, text, "should report the task")
+}
+
+func TestVerifyPipelineRunRequiresNameOrSelector(t *testing.T) {
+	_, o := pipelinerun.NewCmdVerifyPipelineRun()
+	o.DynamicClient = newDynamicClient()
+	o.KubeClient = fake.NewSimpleClientset()
+	o.Namespace = "jx"
+
+	err := o.Run()
+	require.Error(t, err, "should fail without a name or selector")
+}
+
+func assertInOrder(t *testing.T, text string, values ...string) {
+	last := -1
+	for _, v := range values {
+		i := strings.Index(text, v)
+		if !assert.True(t, i >= 0, "should contain %s", v) {
+			continue
+		}
+		assert.Greater(t, i, last, "%s should be after the previous values", v)
+		last = i
+	}
+}
+
+func newDynamicClient(objects ...runtime.Object) *dynfake.FakeDynamicClient {
+	return dynfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), map[schema.GroupVersionResource]string{
+		pipelineRunResource: "PipelineRunList",
+		taskRunResource:     "TaskRunList",
+	}, objects...)
+}
+
+func newPipelineRun(ns, name, status, reason, message string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("tekton.dev/v1")
+	u.SetKind("PipelineRun")
+	u.SetNamespace(ns)
+	u.SetName(name)
+	u.SetLabels(map[string]string{"app": "my-app"})
+	setSucceededCondition(u, status, reason, message)
+	return u
+}
+
+func newTaskRun(ns, pipelineRunName, task, status, reason, message, startTime string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetAPIVersion("tekton.dev/v1")
+	u.SetKind("TaskRun")
+	u.SetNamespace(ns)
+	u.SetName(pipelineRunName + "-" + task)
+	u.SetLabels(map[string]string{
+		builds.LabelPipelineRunName:   pipelineRunName,
+		pipelinerun.LabelPipelineTask: task,
+	})
+	setSucceededCondition(u, status, reason, message)
+	start, _ := time.Parse(time.RFC3339, startTime)
+	_ = unstructured.SetNestedField(u.Object, pipelineRunName+"-"+task+"-pod", "status", "podName")
+	_ = unstructured.SetNestedField(u.Object, startTime, "status", "startTime")
+	if status != "Unknown" {
+		_ = unstructured.SetNestedField(u.Object, start.Add(time.Minute).Format(time.RFC3339), "status", "completionTime")
+	}
+	return u
+}
+
+func setSucceededCondition(u *unstructured.Unstructured, status, reason, message string) {
+	_ = unstructured.SetNestedSlice(u.Object, []interface{}{
+		map[string]interface{}{
+			"type":    "Succeeded",
+			"status":  status,
+			"reason":  reason,
+			"message": message,
+		},
+	}, "status", "conditions")
+}
+
+func newTaskPod(ns, name string, steps ...string) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+	}
+	for _, step := range steps {
+		containerName := "step-" + step
+		pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: containerName})
+		pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, corev1.ContainerStatus{
+			Name: containerName,
+			State: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{},
+			},
+		})
+	}
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{Name: "sidecar"})
+	return pod
+}
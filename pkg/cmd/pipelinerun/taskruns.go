@@ -0,0 +1,263 @@
+package pipelinerun
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/builds"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/pods"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/table"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// stepContainerPrefix the prefix Tekton adds to the names of the containers of the steps of a TaskRun
+const stepContainerPrefix = "step-"
+
+// TaskResult the result of a task of a PipelineRun
+type TaskResult struct {
+	Task     string
+	TaskRun  string
+	Status   string
+	Reason   string
+	Message  string
+	Duration time.Duration
+}
+
+// getTaskRuns returns the TaskRuns of the PipelineRun in the order they started
+func (o *Options) getTaskRuns(ctx context.Context, ns, pipelineRunName string) ([]*unstructured.Unstructured, error) {
+	selector := builds.LabelPipelineRunName + "=" + pipelineRunName
+	list, err := o.DynamicClient.Resource(o.resource("taskruns")).Namespace(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to list TaskRuns in namespace %s with selector %s: %w", ns, selector, err)
+	}
+	if list == nil {
+		return nil, nil
+	}
+
+	var answer []*unstructured.Unstructured
+	for i := range list.Items {
+		answer = append(answer, &list.Items[i])
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		t1 := startTime(answer[i])
+		t2 := startTime(answer[j])
+		if !t1.Equal(t2) {
+			return t1.Before(t2)
+		}
+		return answer[i].GetName() < answer[j].GetName()
+	})
+	return answer, nil
+}
+
+// tailTaskRun tails the logs of the steps of the TaskRun in order which have not already been tailed. As steps
+// run one after the other we stop at the first step which has not started yet and carry on at the next poll
+func (o *Options) tailTaskRun(ctx context.Context, ns string, tr *unstructured.Unstructured) error {
+	podName, _, _ := unstructured.NestedString(tr.Object, "status", "podName")
+	if podName == "" || o.tailed[podName] {
+		return nil
+	}
+	task := taskName(tr)
+
+	pod, err := o.KubeClient.CoreV1().Pods(ns).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Logger().Warnf("cannot tail the log of task %s as pod %s no longer exists", task, podName)
+			o.tailed[podName] = true
+			return nil
+		}
+		return fmt.Errorf("failed to get pod %s in namespace %s: %w", podName, ns, err)
+	}
+
+	firstStep := true
+	for i := range pod.Spec.Containers {
+		containerName := pod.Spec.Containers[i].Name
+		if !strings.HasPrefix(containerName, stepContainerPrefix) {
+			continue
+		}
+		key := podName + "/" + containerName
+		if o.tailed[key] {
+			firstStep = false
+			continue
+		}
+		if !pods.IsPodCompleted(pod) && !isContainerStarted(pod, containerName) {
+			return nil
+		}
+
+		if firstStep {
+			firstStep = false
+			logger.Logger().Infof("\ntailing task %s pod %s\n\n", info(task), info(podName))
+		}
+		o.tailed[key] = true
+		err = o.tailStep(ctx, ns, podName, containerName, task)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			logger.Logger().Warnf("failed to tail the log of step %s of task %s: %s", containerName, task, err.Error())
+		}
+	}
+	o.tailed[podName] = true
+	return nil
+}
+
+// tailStep follows the log of the step container writing each line prefixed with the task and step name
+func (o *Options) tailStep(ctx context.Context, ns, podName, containerName, task string) error {
+	reader, err := o.LogSource.OpenLog(ctx, ns, podName, &corev1.PodLogOptions{
+		Container: containerName,
+		Follow:    true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	defer reader.Close()
+
+	prefix := termcolor.ColorStatus(fmt.Sprintf("[%s:%s]", task, strings.TrimPrefix(containerName, stepContainerPrefix)))
+	r := bufio.NewReader(reader)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			_, werr := fmt.Fprintf(o.Out, "%s %s\n", prefix, strings.TrimSuffix(line, "\n"))
+			if werr != nil {
+				return werr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func isContainerStarted(pod *corev1.Pod, containerName string) bool {
+	for i := range pod.Status.ContainerStatuses {
+		s := &pod.Status.ContainerStatuses[i]
+		if s.Name == containerName {
+			return s.State.Running != nil || s.State.Terminated != nil
+		}
+	}
+	return false
+}
+
+// getTaskResults returns the results of the TaskRuns along with any tasks the PipelineRun skipped
+func getTaskResults(pr *unstructured.Unstructured, taskRuns []*unstructured.Unstructured) []TaskResult {
+	var answer []TaskResult
+	for _, tr := range taskRuns {
+		status, reason, message := succeededCondition(tr)
+		r := TaskResult{
+			Task:    taskName(tr),
+			TaskRun: tr.GetName(),
+			Reason:  reason,
+			Message: message,
+		}
+		switch status {
+		case string(metav1.ConditionTrue):
+			r.Status = "Succeeded"
+		case string(metav1.ConditionFalse):
+			r.Status = "Failed"
+		case string(metav1.ConditionUnknown):
+			r.Status = "Running"
+		default:
+			r.Status = "Pending"
+		}
+		start := startTime(tr)
+		if !start.IsZero() {
+			end := parseTime(tr, "status", "completionTime")
+			if end.IsZero() {
+				end = time.Now()
+			}
+			r.Duration = end.Sub(start)
+		}
+		answer = append(answer, r)
+	}
+
+	skippedTasks, _, _ := unstructured.NestedSlice(pr.Object, "status", "skippedTasks")
+	for _, s := range skippedTasks {
+		m, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(m, "name")
+		reason, _, _ := unstructured.NestedString(m, "reason")
+		answer = append(answer, TaskResult{
+			Task:   name,
+			Status: "Skipped",
+			Reason: reason,
+		})
+	}
+	return answer
+}
+
+// reportTaskResults writes a table of the results of the tasks and logs why any failed
+func (o *Options) reportTaskResults(results []TaskResult) {
+	t := table.CreateTable(o.Out)
+	t.AddRow("TASK", "TASKRUN", "STATUS", "DURATION", "REASON")
+	for i := range results {
+		r := &results[i]
+		status := r.Status
+		switch status {
+		case "Succeeded":
+			status = info(status)
+		case "Failed":
+			status = termcolor.ColorError(status)
+		default:
+			status = termcolor.ColorWarning(status)
+		}
+		duration := ""
+		if r.Duration > 0 {
+			duration = r.Duration.Round(time.Second).String()
+		}
+		t.AddRow(r.Task, r.TaskRun, status, duration, r.Reason)
+	}
+	t.Render()
+
+	for i := range results {
+		r := &results[i]
+		if r.Status == "Failed" {
+			logger.Logger().Infof("task %s failed: %s", info(r.Task), r.Message)
+		}
+	}
+}
+
+// taskName returns the name of the task in the pipeline of the TaskRun
+func taskName(tr *unstructured.Unstructured) string {
+	name := tr.GetLabels()[LabelPipelineTask]
+	if name == "" {
+		name = tr.GetName()
+	}
+	return name
+}
+
+func startTime(u *unstructured.Unstructured) time.Time {
+	answer := parseTime(u, "status", "startTime")
+	if answer.IsZero() {
+		answer = u.GetCreationTimestamp().Time
+	}
+	return answer
+}
+
+func parseTime(u *unstructured.Unstructured, fields ...string) time.Time {
+	text, _, _ := unstructured.NestedString(u.Object, fields...)
+	if text == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, text)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
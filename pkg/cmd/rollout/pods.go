@@ -0,0 +1,178 @@
+package rollout
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube/pods"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// revisionAnnotation the annotation the deployment controller adds to ReplicaSets with the revision of the Deployment
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// reportFailingPods displays the status and the end of the logs of the failing pods of the latest revision
+func (o *Options) reportFailingPods(ctx context.Context, ns string, s *rolloutStatus) {
+	if ctx.Err() != nil || s.selector == nil {
+		return
+	}
+	failingPods, err := o.getFailingPods(ctx, ns, s)
+	if err != nil {
+		logger.Logger().Warnf("failed to find the failing pods of %s %s: %s", o.Kind, o.Name, err.Error())
+		return
+	}
+	if len(failingPods) == 0 {
+		return
+	}
+	if o.MaxPods > 0 && len(failingPods) > o.MaxPods {
+		logger.Logger().Infof("showing %d of the %d failing pods of %s %s", o.MaxPods, len(failingPods), o.Kind, info(o.Name))
+		failingPods = failingPods[:o.MaxPods]
+	}
+	for i := range failingPods {
+		o.reportFailingPod(ctx, ns, &failingPods[i])
+	}
+}
+
+// getFailingPods returns the pods of the latest revision of the workload which are not ready
+func (o *Options) getFailingPods(ctx context.Context, ns string, s *rolloutStatus) ([]corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(s.selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse selector of %s %s: %w", o.Kind, o.Name, err)
+	}
+
+	revision := s.revision
+	if o.Kind == KindDeployment {
+		revision, err = o.getNewestReplicaSetHash(ctx, ns, selector.String())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	podList, err := o.KubeClient.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s with selector %s: %w", ns, selector.String(), err)
+	}
+	var answer []corev1.Pod
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if revision != "" && pod.Labels[s.revisionLabel] != revision {
+			continue
+		}
+		if pod.DeletionTimestamp == nil && !pods.IsPodReady(pod) {
+			answer = append(answer, *pod)
+		}
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[j].CreationTimestamp.Before(&answer[i].CreationTimestamp)
+	})
+	return answer, nil
+}
+
+// getNewestReplicaSetHash returns the pod template hash of the newest ReplicaSet of the Deployment
+func (o *Options) getNewestReplicaSetHash(ctx context.Context, ns, selector string) (string, error) {
+	rsList, err := o.KubeClient.AppsV1().ReplicaSets(ns).List(ctx, metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list ReplicaSets in namespace %s with selector %s: %w", ns, selector, err)
+	}
+	var newest *appsv1.ReplicaSet
+	newestRevision := int64(-1)
+	for i := range rsList.Items {
+		rs := &rsList.Items[i]
+		owner := metav1.GetControllerOf(rs)
+		if owner == nil || owner.Kind != "Deployment" || owner.Name != o.Name {
+			continue
+		}
+		revision, _ := strconv.ParseInt(rs.Annotations[revisionAnnotation], 10, 64)
+		if newest == nil || revision > newestRevision {
+			newest = rs
+			newestRevision = revision
+		}
+	}
+	if newest == nil {
+		return "", nil
+	}
+	logger.Logger().Infof("newest ReplicaSet of deployment %s is %s", info(o.Name), info(newest.Name))
+	return newest.Labels[appsv1.DefaultDeploymentUniqueLabelKey], nil
+}
+
+// reportFailingPod displays the status of the containers of the pod and the end of their logs
+func (o *Options) reportFailingPod(ctx context.Context, ns string, pod *corev1.Pod) {
+	logger.Logger().Infof("\npod %s is %s", info(pod.Name), termcolor.ColorWarning(pods.PodStatus(pod)))
+
+	statuses := append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for i := range statuses {
+		cs := &statuses[i]
+		if cs.Ready {
+			continue
+		}
+		reason := ""
+		switch {
+		case cs.State.Waiting != nil:
+			reason = cs.State.Waiting.Reason + " " + cs.State.Waiting.Message
+		case cs.State.Terminated != nil:
+			reason = fmt.Sprintf("terminated with exit code %d %s", cs.State.Terminated.ExitCode, cs.State.Terminated.Reason)
+		default:
+			reason = "not ready"
+		}
+		logger.Logger().Infof("container %s: %s restarts %d", info(cs.Name), termcolor.ColorWarning(reason), cs.RestartCount)
+
+		// if the container is waiting to be restarted its current log is empty so lets show the previous one
+		previous := cs.State.Waiting != nil && cs.RestartCount > 0
+		if cs.State.Waiting != nil && !previous {
+			continue
+		}
+		err := o.tailContainerLog(ctx, ns, pod.Name, cs.Name, previous)
+		if err != nil {
+			logger.Logger().Warnf("failed to get the log of container %s of pod %s: %s", cs.Name, pod.Name, err.Error())
+		}
+	}
+}
+
+// tailContainerLog writes the end of the log of the container prefixed with the pod and container name
+func (o *Options) tailContainerLog(ctx context.Context, ns, podName, containerName string, previous bool) error {
+	opts := &corev1.PodLogOptions{
+		Container: containerName,
+		Previous:  previous,
+	}
+	if o.TailLines > 0 {
+		tailLines := o.TailLines
+		opts.TailLines = &tailLines
+	}
+	reader, err := o.LogSource.OpenLog(ctx, ns, podName, opts)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	prefix := termcolor.ColorStatus(fmt.Sprintf("[%s/%s]", podName, containerName))
+	r := bufio.NewReader(reader)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			fmt.Fprintf(o.Out, "%s %s", prefix, line)
+			if line[len(line)-1] != '\n' {
+				fmt.Fprintln(o.Out)
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/podlogs"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/signals"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/kube"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/options"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/stringhelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-kube-client/v3/pkg/kubeclient"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// KindDeployment verifies the rollout of a Deployment
+	KindDeployment = "deployment"
+
+	// KindStatefulSet verifies the rollout of a StatefulSet
+	KindStatefulSet = "statefulset"
+
+	// KindDaemonSet verifies the rollout of a DaemonSet
+	KindDaemonSet = "daemonset"
+)
+
+// KindValues the valid values of the kind option
+var KindValues = []string{KindDeployment, KindStatefulSet, KindDaemonSet}
+
+// kindAliases the short names of the kinds which can also be used
+var kindAliases = map[string]string{
+	"deploy":       KindDeployment,
+	"deployments":  KindDeployment,
+	"sts":          KindStatefulSet,
+	"statefulsets": KindStatefulSet,
+	"ds":           KindDaemonSet,
+	"daemonsets":   KindDaemonSet,
+}
+
+// Options the options for verifying the rollout of a workload
+type Options struct {
+	options.BaseOptions
+
+	Namespace  string
+	Kind       string
+	Name       string
+	Duration   time.Duration
+	PollPeriod time.Duration
+	TailLines  int64
+	MaxPods    int
+	Out        io.Writer
+	KubeClient kubernetes.Interface
+	LogSource  podlogs.LogSource
+	timeEnd    time.Time
+}
+
+var (
+	info = termcolor.ColorInfo
+
+	cmdLong = templates.LongDesc(`
+		Verifies that a Deployment, StatefulSet or DaemonSet has rolled out
+
+		Waits for the controller to observe the latest generation and for the updated, ready and available replica counts to converge in the same way as 'kubectl rollout status'. A Deployment which exceeds its progress deadline fails straight away.
+
+		If the rollout fails the status and the end of the logs of the failing pods of the latest revision are displayed.
+`)
+
+	cmdExample = templates.Examples(`
+		# verify the Deployment rolls out
+		jx verify rollout --name my-app
+
+		# verify the StatefulSet rolls out within 5 minutes
+		jx verify rollout --kind statefulset --name my-db --duration 5m
+`)
+)
+
+// NewCmdVerifyRollout creates the new command
+func NewCmdVerifyRollout() (*cobra.Command, *Options) {
+	o := &Options{}
+	command := &cobra.Command{
+		Use:     "rollout",
+		Short:   "Verifies that a Deployment, StatefulSet or DaemonSet has rolled out",
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			signals.RunUntilSignalled(o.RunWithContext)
+		},
+	}
+	command.Flags().StringVarP(&o.Namespace, "namespace", "n", "", "the namespace of the workload. If not specified the current namespace is used")
+	command.Flags().StringVarP(&o.Kind, "kind", "k", KindDeployment, "the kind of the workload. Values: "+strings.Join(KindValues, ", "))
+	command.Flags().StringVarP(&o.Name, "name", "", "", "the name of the workload")
+	command.Flags().DurationVarP(&o.Duration, "duration", "d", time.Minute*10, "how long to wait for the rollout to complete")
+	command.Flags().DurationVarP(&o.PollPeriod, "poll", "", time.Second*2, "the period between checks of the status of the rollout")
+	command.Flags().Int64VarP(&o.TailLines, "tail", "", 50, "the number of lines at the end of the log of each container of the failing pods to display if the rollout fails")
+	command.Flags().IntVarP(&o.MaxPods, "max-pods", "", 3, "the maximum number of failing pods to display the logs of if the rollout fails")
+
+	o.BaseOptions.AddBaseFlags(command)
+	return command, o
+}
+
+// Validate checks the workload name and kind, resolving any kind alias, and lazily creates the kubernetes and log clients
+func (o *Options) Validate() error {
+	if o.Name == "" {
+		return options.MissingOption("name")
+	}
+	o.Kind = strings.ToLower(o.Kind)
+	if o.Kind == "" {
+		o.Kind = KindDeployment
+	}
+	if kind, ok := kindAliases[o.Kind]; ok {
+		o.Kind = kind
+	}
+	if stringhelpers.StringArrayIndex(KindValues, o.Kind) < 0 {
+		return options.InvalidOption("kind", o.Kind, KindValues)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+
+	var err error
+	o.KubeClient, err = kube.LazyCreateKubeClientWithMandatory(o.KubeClient, true)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	if o.LogSource == nil {
+		o.LogSource = podlogs.NewKubeLogSource(o.KubeClient)
+	}
+	if o.Namespace == "" {
+		o.Namespace, err = kubeclient.CurrentNamespace()
+		if err != nil {
+			return fmt.Errorf("failed to detect current namespace. Try supply --namespace: %w", err)
+		}
+	}
+	o.timeEnd = time.Now().Add(o.Duration)
+	return nil
+}
+
+// Run runs the command
+func (o *Options) Run() error {
+	return o.RunWithContext(context.Background())
+}
+
+// RunWithContext runs the command until the rollout completes or fails or the context is cancelled
+func (o *Options) RunWithContext(ctx context.Context) error {
+	err := o.Validate()
+	if err != nil {
+		return err
+	}
+	ns := o.Namespace
+
+	logger.Logger().Infof("waiting up to %s for the rollout of %s %s in namespace %s", o.Duration.String(), o.Kind, info(o.Name), info(ns))
+	lastMessage := ""
+	for {
+		s, err := o.getRolloutStatus(ctx, ns)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if s.err != nil {
+			o.reportFailingPods(ctx, ns, s)
+			return s.err
+		}
+		if s.done {
+			logger.Logger().Infof("%s %s has %s", o.Kind, info(o.Name), info("successfully rolled out"))
+			return nil
+		}
+		if s.message != lastMessage {
+			lastMessage = s.message
+			logger.Logger().Infof("waiting for the rollout of %s %s: %s", o.Kind, info(o.Name), s.message)
+		}
+
+		if time.Now().After(o.timeEnd) {
+			o.reportFailingPods(ctx, ns, s)
+			return fmt.Errorf("timed out after waiting for duration %s for the rollout of %s %s: %s", o.Duration.String(), o.Kind, o.Name, s.message)
+		}
+		timer := time.NewTimer(o.PollPeriod)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
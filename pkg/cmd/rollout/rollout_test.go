@@ -0,0 +1,385 @@
+package rollout_test
+
+import (
+	"bytes"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/rollout"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/internal/testhelpers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestVerifyRollout(t *testing.T) {
+	ns := "jx"
+	name := "my-app"
+	replicas := int32(2)
+	partition := int32(1)
+
+	testCases := []struct {
+		name    string
+		kind    string
+		object  runtime.Object
+		errText string
+	}{
+		{
+			name: "deployment rolled out",
+			kind: "deploy",
+			object: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: 2},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2,
+					Replicas:           2,
+					UpdatedReplicas:    2,
+					ReadyReplicas:      2,
+					AvailableReplicas:  2,
+				},
+			},
+		},
+		{
+			name: "deployment not observed",
+			kind: rollout.KindDeployment,
+			object: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: 3},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					ObservedGeneration: 2,
+					Replicas:           2,
+					UpdatedReplicas:    2,
+					ReadyReplicas:      2,
+					AvailableReplicas:  2,
+				},
+			},
+			errText: "waiting for the deployment spec update to be observed",
+		},
+		{
+			name: "deployment old replicas",
+			kind: rollout.KindDeployment,
+			object: &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: appsv1.DeploymentStatus{
+					Replicas:          3,
+					UpdatedReplicas:   2,
+					ReadyReplicas:     3,
+					AvailableReplicas: 3,
+				},
+			},
+			errText: "1 old replicas are pending termination",
+		},
+		{
+			name:    "deployment missing",
+			kind:    rollout.KindDeployment,
+			errText: "waiting for deployment my-app to be created",
+		},
+		{
+			name: "statefulset rolled out",
+			kind: "sts",
+			object: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: 1},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status: appsv1.StatefulSetStatus{
+					ObservedGeneration: 1,
+					Replicas:           2,
+					ReadyReplicas:      2,
+					AvailableReplicas:  2,
+					UpdatedReplicas:    2,
+					CurrentRevision:    "my-app-abc",
+					UpdateRevision:     "my-app-abc",
+				},
+			},
+		},
+		{
+			name: "statefulset updating",
+			kind: rollout.KindStatefulSet,
+			object: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: 1},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas},
+				Status: appsv1.StatefulSetStatus{
+					ObservedGeneration: 1,
+					Replicas:           2,
+					ReadyReplicas:      2,
+					AvailableReplicas:  2,
+					UpdatedReplicas:    1,
+					CurrentRevision:    "my-app-abc",
+					UpdateRevision:     "my-app-def",
+				},
+			},
+			errText: "1 out of 2 new pods have been updated to revision my-app-def",
+		},
+		{
+			name: "statefulset partitioned",
+			kind: rollout.KindStatefulSet,
+			object: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: 1},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas: &replicas,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+						Type: appsv1.RollingUpdateStatefulSetStrategyType,
+						RollingUpdate: &appsv1.RollingUpdateStatefulSetStrategy{
+							Partition: &partition,
+						},
+					},
+				},
+				Status: appsv1.StatefulSetStatus{
+					ObservedGeneration: 1,
+					Replicas:           2,
+					ReadyReplicas:      2,
+					AvailableReplicas:  2,
+					UpdatedReplicas:    1,
+					CurrentRevision:    "my-app-abc",
+					UpdateRevision:     "my-app-def",
+				},
+			},
+		},
+		{
+			name: "statefulset on delete",
+			kind: rollout.KindStatefulSet,
+			object: &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, Generation: 1},
+				Spec: appsv1.StatefulSetSpec{
+					Replicas: &replicas,
+					UpdateStrategy: appsv1.StatefulSetUpdateStrategy{
+						Type: appsv1.OnDeleteStatefulSetStrategyType,
+					},
+				},
+			},
+			errText: "uses the OnDelete update strategy",
+		},
+		{
+			name: "daemonset rolled out",
+			kind: "ds",
+			object: &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Status: appsv1.DaemonSetStatus{
+					DesiredNumberScheduled: 3,
+					UpdatedNumberScheduled: 3,
+					NumberReady:            3,
+					NumberAvailable:        3,
+				},
+			},
+		},
+		{
+			name: "daemonset unavailable",
+			kind: rollout.KindDaemonSet,
+			object: &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Status: appsv1.DaemonSetStatus{
+					DesiredNumberScheduled: 3,
+					UpdatedNumberScheduled: 3,
+					NumberReady:            3,
+					NumberAvailable:        2,
+				},
+			},
+			errText: "2 of 3 updated pods are available",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var objects []runtime.Object
+			if tc.object != nil {
+				objects = append(objects, tc.object)
+			}
+
+			_, o := rollout.NewCmdVerifyRollout()
+			o.KubeClient = fake.NewSimpleClientset(objects...)
+			o.LogSource = &testhelpers.FakeLogSource{}
+			o.Namespace = ns
+			o.Kind = tc.kind
+			o.Name = name
+			o.Duration = 0
+			o.Out = &bytes.Buffer{}
+
+			err := o.Run()
+			if tc.errText == "" {
+				require.NoError(t, err, "should have verified the rollout")
+				return
+			}
+			require.Error(t, err, "should have failed")
+			assert.Contains(t, err.Error(), tc.errText)
+		})
+	}
+}
+
+func TestVerifyRolloutWaitsForConvergence(t *testing.T) {
+	ns := "jx"
+	name := "my-app"
+	replicas := int32(2)
+
+	kubeClient := fake.NewSimpleClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          2,
+			UpdatedReplicas:   2,
+			ReadyReplicas:     1,
+			AvailableReplicas: 1,
+		},
+	})
+
+	// lets make the deployment available after a few checks
+	var gets int32
+	kubeClient.PrependReactor("get", "deployments", func(_ k8stesting.Action) (bool, runtime.Object, error) {
+		if atomic.AddInt32(&gets, 1) < 3 {
+			return false, nil, nil
+		}
+		return true, &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{
+				Replicas:          2,
+				UpdatedReplicas:   2,
+				ReadyReplicas:     2,
+				AvailableReplicas: 2,
+			},
+		}, nil
+	})
+
+	_, o := rollout.NewCmdVerifyRollout()
+	o.KubeClient = kubeClient
+	o.LogSource = &testhelpers.FakeLogSource{}
+	o.Namespace = ns
+	o.Name = name
+	o.Duration = 10 * time.Second
+	o.PollPeriod = 10 * time.Millisecond
+	o.Out = &bytes.Buffer{}
+
+	err := o.Run()
+	require.NoError(t, err, "should have waited for the deployment to be available")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&gets), "number of checks")
+}
+
+func TestVerifyRolloutProgressDeadlineExceeded(t *testing.T) {
+	ns := "jx"
+	name := "my-app"
+	replicas := int32(1)
+	labels := map[string]string{"app": name}
+	selector := &metav1.LabelSelector{MatchLabels: labels}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns, UID: "deploy-uid"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: selector,
+		},
+		Status: appsv1.DeploymentStatus{
+			Replicas:          2,
+			UpdatedReplicas:   1,
+			ReadyReplicas:     1,
+			AvailableReplicas: 1,
+			Conditions: []appsv1.DeploymentCondition{
+				{
+					Type:    appsv1.DeploymentProgressing,
+					Status:  corev1.ConditionFalse,
+					Reason:  "ProgressDeadlineExceeded",
+					Message: `ReplicaSet "my-app-v2" has timed out progressing.`,
+				},
+			},
+		},
+	}
+
+	_, o := rollout.NewCmdVerifyRollout()
+	o.KubeClient = fake.NewSimpleClientset(
+		deployment,
+		newReplicaSet(ns, "my-app-v1", "v1", "1", deployment),
+		newReplicaSet(ns, "my-app-v2", "v2", "2", deployment),
+		newPod(ns, "my-app-v1-abc", "v1", true),
+		newPod(ns, "my-app-v2-abc", "v2", false),
+	)
+	o.LogSource = &testhelpers.FakeLogSource{
+		Logs: map[string]string{
+			"my-app-v1-abc/app":          "old version is fine\n",
+			"my-app-v2-abc/app.previous": "panic: missing config\n",
+		},
+	}
+	o.Namespace = ns
+	o.Name = name
+	o.Duration = time.Minute
+	out := &bytes.Buffer{}
+	o.Out = out
+
+	err := o.Run()
+	require.Error(t, err, "should have failed as the deployment exceeded its progress deadline")
+	assert.Contains(t, err.Error(), "deployment my-app exceeded its progress deadline")
+
+	text := out.String()
+	t.Logf("output:\n%s\n", text)
+	assert.Contains(t, text, "[my-app-v2-abc/app] panic: missing config", "should show the previous log of the crashing pod")
+	assert.NotContains(t, text, "old version is fine", "should only show the pods of the newest ReplicaSet")
+}
+
+func newReplicaSet(ns, name, hash, revision string, d *appsv1.Deployment) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				"app":                                  d.Name,
+				appsv1.DefaultDeploymentUniqueLabelKey: hash,
+			},
+			Annotations: map[string]string{
+				"deployment.kubernetes.io/revision": revision,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment")),
+			},
+		},
+	}
+}
+
+func newPod(ns, name, hash string, ready bool) *corev1.Pod {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels: map[string]string{
+				"app":                                  "my-app",
+				appsv1.DefaultDeploymentUniqueLabelKey: hash,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{
+					Type:   corev1.PodReady,
+					Status: corev1.ConditionTrue,
+				},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{
+				{
+					Name:  "app",
+					Ready: true,
+					State: corev1.ContainerState{
+						Running: &corev1.ContainerStateRunning{},
+					},
+				},
+			},
+		},
+	}
+	if !ready {
+		pod.Status.Conditions[0].Status = corev1.ConditionFalse
+		pod.Status.ContainerStatuses[0] = corev1.ContainerStatus{
+			Name:         "app",
+			RestartCount: 4,
+			State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{
+					Reason:  "CrashLoopBackOff",
+					Message: "back-off 1m20s restarting failed container",
+				},
+			},
+		}
+	}
+	return pod
+}
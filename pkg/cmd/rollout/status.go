@@ -0,0 +1,169 @@
+package rollout
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// progressDeadlineExceeded the reason of the Progressing condition of a Deployment which has stopped making progress
+const progressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// rolloutStatus the status of the rollout of a workload
+type rolloutStatus struct {
+	message string
+	done    bool
+	err     error
+
+	// the selector of the pods of the workload and, if known, the label and value of the pods of the latest revision
+	selector      *metav1.LabelSelector
+	revisionLabel string
+	revision      string
+}
+
+// getRolloutStatus gets the workload and returns the status of its rollout
+func (o *Options) getRolloutStatus(ctx context.Context, ns string) (*rolloutStatus, error) {
+	apps := o.KubeClient.AppsV1()
+	var err error
+	var s *rolloutStatus
+	switch o.Kind {
+	case KindStatefulSet:
+		var sts *appsv1.StatefulSet
+		sts, err = apps.StatefulSets(ns).Get(ctx, o.Name, metav1.GetOptions{})
+		if err == nil {
+			s = statefulSetStatus(sts)
+		}
+	case KindDaemonSet:
+		var ds *appsv1.DaemonSet
+		ds, err = apps.DaemonSets(ns).Get(ctx, o.Name, metav1.GetOptions{})
+		if err == nil {
+			s = daemonSetStatus(ds)
+		}
+	default:
+		var d *appsv1.Deployment
+		d, err = apps.Deployments(ns).Get(ctx, o.Name, metav1.GetOptions{})
+		if err == nil {
+			s = deploymentStatus(d)
+		}
+	}
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return &rolloutStatus{
+				message: fmt.Sprintf("waiting for %s %s to be created", o.Kind, o.Name),
+			}, nil
+		}
+		return nil, fmt.Errorf("failed to get %s %s in namespace %s: %w", o.Kind, o.Name, ns, err)
+	}
+	return s, nil
+}
+
+// deploymentStatus returns the status of the rollout of the Deployment using the same rules as kubectl rollout status
+func deploymentStatus(d *appsv1.Deployment) *rolloutStatus {
+	s := &rolloutStatus{
+		selector:      d.Spec.Selector,
+		revisionLabel: appsv1.DefaultDeploymentUniqueLabelKey,
+	}
+	if d.Generation > d.Status.ObservedGeneration {
+		s.message = "waiting for the deployment spec update to be observed"
+		return s
+	}
+	for i := range d.Status.Conditions {
+		c := &d.Status.Conditions[i]
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == progressDeadlineExceeded {
+			s.err = fmt.Errorf("deployment %s exceeded its progress deadline: %s", d.Name, c.Message)
+			return s
+		}
+	}
+	replicas := d.Status.Replicas
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	switch {
+	case d.Status.UpdatedReplicas < replicas:
+		s.message = fmt.Sprintf("%d out of %d new replicas have been updated", d.Status.UpdatedReplicas, replicas)
+	case d.Status.Replicas > d.Status.UpdatedReplicas:
+		s.message = fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas)
+	case d.Status.ReadyReplicas < d.Status.UpdatedReplicas:
+		s.message = fmt.Sprintf("%d of %d updated replicas are ready", d.Status.ReadyReplicas, d.Status.UpdatedReplicas)
+	case d.Status.AvailableReplicas < d.Status.UpdatedReplicas:
+		s.message = fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas)
+	default:
+		s.done = true
+	}
+	return s
+}
+
+// statefulSetStatus returns the status of the rollout of the StatefulSet using the same rules as kubectl rollout status
+func statefulSetStatus(sts *appsv1.StatefulSet) *rolloutStatus {
+	s := &rolloutStatus{
+		selector:      sts.Spec.Selector,
+		revisionLabel: appsv1.ControllerRevisionHashLabelKey,
+		revision:      sts.Status.UpdateRevision,
+	}
+	if sts.Spec.UpdateStrategy.Type != "" && sts.Spec.UpdateStrategy.Type != appsv1.RollingUpdateStatefulSetStrategyType {
+		s.err = fmt.Errorf("the rollout of statefulset %s cannot be verified as it uses the %s update strategy", sts.Name, sts.Spec.UpdateStrategy.Type)
+		return s
+	}
+	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+		s.message = "waiting for the statefulset spec update to be observed"
+		return s
+	}
+	replicas := sts.Status.Replicas
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	if sts.Status.ReadyReplicas < replicas {
+		s.message = fmt.Sprintf("%d of %d pods are ready", sts.Status.ReadyReplicas, replicas)
+		return s
+	}
+	if sts.Status.AvailableReplicas < replicas {
+		s.message = fmt.Sprintf("%d of %d pods are available", sts.Status.AvailableReplicas, replicas)
+		return s
+	}
+	rollingUpdate := sts.Spec.UpdateStrategy.RollingUpdate
+	if rollingUpdate != nil && rollingUpdate.Partition != nil && *rollingUpdate.Partition > 0 {
+		updated := replicas - *rollingUpdate.Partition
+		if sts.Status.UpdatedReplicas < updated {
+			s.message = fmt.Sprintf("waiting for the partitioned rollout to finish: %d out of %d new pods have been updated", sts.Status.UpdatedReplicas, updated)
+			return s
+		}
+		s.done = true
+		return s
+	}
+	if sts.Status.UpdateRevision != sts.Status.CurrentRevision {
+		s.message = fmt.Sprintf("%d out of %d new pods have been updated to revision %s", sts.Status.UpdatedReplicas, replicas, sts.Status.UpdateRevision)
+		return s
+	}
+	s.done = true
+	return s
+}
+
+// daemonSetStatus returns the status of the rollout of the DaemonSet using the same rules as kubectl rollout status
+func daemonSetStatus(ds *appsv1.DaemonSet) *rolloutStatus {
+	s := &rolloutStatus{
+		selector: ds.Spec.Selector,
+	}
+	if ds.Spec.UpdateStrategy.Type != "" && ds.Spec.UpdateStrategy.Type != appsv1.RollingUpdateDaemonSetStrategyType {
+		s.err = fmt.Errorf("the rollout of daemonset %s cannot be verified as it uses the %s update strategy", ds.Name, ds.Spec.UpdateStrategy.Type)
+		return s
+	}
+	if ds.Generation > ds.Status.ObservedGeneration {
+		s.message = "waiting for the daemonset spec update to be observed"
+		return s
+	}
+	desired := ds.Status.DesiredNumberScheduled
+	switch {
+	case ds.Status.UpdatedNumberScheduled < desired:
+		s.message = fmt.Sprintf("%d out of %d new pods have been updated", ds.Status.UpdatedNumberScheduled, desired)
+	case ds.Status.NumberReady < desired:
+		s.message = fmt.Sprintf("%d of %d updated pods are ready", ds.Status.NumberReady, desired)
+	case ds.Status.NumberAvailable < desired:
+		s.message = fmt.Sprintf("%d of %d updated pods are available", ds.Status.NumberAvailable, desired)
+	default:
+		s.done = true
+	}
+	return s
+}
@@ -2,10 +2,13 @@ package cmd
 
 import (
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/ctx"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/helmtest"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/ingress"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/install"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/job"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/pipelinerun"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/pods"
+	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/rollout"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/tls"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/cmd/version"
 	"github.com/jenkins-x-plugins/jx-verify/pkg/rootcmd"
@@ -29,10 +32,13 @@ func Main() *cobra.Command {
 		},
 	}
 	cmd.AddCommand(cobras.SplitCommand(ctx.NewCmdVerifyContext()))
+	cmd.AddCommand(cobras.SplitCommand(helmtest.NewCmdVerifyHelmTest()))
 	cmd.AddCommand(cobras.SplitCommand(ingress.NewCmdVerifyIngress()))
 	cmd.AddCommand(cobras.SplitCommand(install.NewCmdVerifyInstall()))
 	cmd.AddCommand(cobras.SplitCommand(job.NewCmdVerifyJob()))
+	cmd.AddCommand(cobras.SplitCommand(pipelinerun.NewCmdVerifyPipelineRun()))
 	cmd.AddCommand(cobras.SplitCommand(pods.NewCmdVerifyPods()))
+	cmd.AddCommand(cobras.SplitCommand(rollout.NewCmdVerifyRollout()))
 	cmd.AddCommand(cobras.SplitCommand(tls.NewCmdVerifyTLS()))
 	cmd.AddCommand(cobras.SplitCommand(version.NewCmdVersion()))
 
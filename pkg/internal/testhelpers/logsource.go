@@ -0,0 +1,34 @@
+// Package testhelpers contains test doubles shared by the tests of the commands
+package testhelpers
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// FakeLogSource a podlogs.LogSource which returns the logs keyed by pod name and container name, or just the pod name,
+// calling OnFollow whenever a log is followed so that tests can complete the pod once its log has been tailed.
+// The previous log of a container is keyed with a .previous suffix
+type FakeLogSource struct {
+	Logs     map[string]string
+	OnFollow func(podName, containerName string)
+}
+
+// OpenLog returns the log of the container of the pod
+func (s *FakeLogSource) OpenLog(_ context.Context, _, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	key := podName + "/" + opts.Container
+	if opts.Previous {
+		key += ".previous"
+	}
+	text, ok := s.Logs[key]
+	if !ok && !opts.Previous {
+		text = s.Logs[podName]
+	}
+	if opts.Follow && s.OnFollow != nil {
+		s.OnFollow(podName, opts.Container)
+	}
+	return io.NopCloser(strings.NewReader(text)), nil
+}
@@ -0,0 +1,30 @@
+// Package podlogs opens the logs of pod containers
+package podlogs
+
+import (
+	"context"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LogSource opens the logs of pod containers so that tailing can be tested without a cluster
+type LogSource interface {
+	// OpenLog opens a stream of the log of the pod using the given options which specify the container
+	OpenLog(ctx context.Context, ns, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error)
+}
+
+// NewKubeLogSource creates a LogSource which streams logs from the Kubernetes API server
+func NewKubeLogSource(client kubernetes.Interface) LogSource {
+	return &kubeLogSource{client: client}
+}
+
+type kubeLogSource struct {
+	client kubernetes.Interface
+}
+
+// OpenLog opens a stream of the log of the pod
+func (s *kubeLogSource) OpenLog(ctx context.Context, ns, podName string, opts *corev1.PodLogOptions) (io.ReadCloser, error) {
+	return s.client.CoreV1().Pods(ns).GetLogs(podName, opts).Stream(ctx)
+}
@@ -0,0 +1,29 @@
+// Package signals runs commands which are cancelled by SIGINT or SIGTERM
+package signals
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	logger "github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// CancelledExitCode the exit code of the command if it is cancelled by SIGINT or SIGTERM
+const CancelledExitCode = 130
+
+// RunUntilSignalled runs a command with a context which is cancelled by SIGINT or SIGTERM. If the command is
+// cancelled the process exits with CancelledExitCode rather than the exit code of a failed command
+func RunUntilSignalled(run func(ctx context.Context) error) {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := run(ctx)
+	cancel()
+	if errors.Is(err, context.Canceled) {
+		logger.Logger().Warnf("%s", err.Error())
+		os.Exit(CancelledExitCode)
+	}
+	helper.CheckErr(err)
+}